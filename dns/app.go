@@ -0,0 +1,123 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/azazeal/fly/env"
+)
+
+// ErrInvalidAppName is returned for invalid application names.
+var ErrInvalidAppName = errors.New("dns: invalid application name")
+
+// ValidateAppName reports whether name is a valid application name; that is a
+// name of up to 63 lowercase letters, digits and dashes which neither starts
+// nor ends with a dash.
+func ValidateAppName(name string) error {
+	if l := len(name); l == 0 || l > 63 {
+		return fmt.Errorf("%w: %q", ErrInvalidAppName, name)
+	} else if name[0] == '-' || name[l-1] == '-' {
+		return fmt.Errorf("%w: %q", ErrInvalidAppName, name)
+	}
+
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return fmt.Errorf("%w: %q", ErrInvalidAppName, name)
+		}
+	}
+
+	return nil
+}
+
+// Handle wraps the functionality of DNS which is bound to an application.
+type Handle struct {
+	dns  DNS
+	name string
+}
+
+// NewHandle returns a Handle for the named application which uses d.
+func NewHandle(d DNS, appName string) (*Handle, error) {
+	if err := ValidateAppName(appName); err != nil {
+		return nil, err
+	}
+
+	return &Handle{
+		dns:  d,
+		name: appName,
+	}, nil
+}
+
+// App returns a Handle for the named application.
+func App(name string) (*Handle, error) {
+	return NewHandle(global, name)
+}
+
+// Self returns a Handle for the current application (see env.AppName).
+func Self() (*Handle, error) {
+	name := env.AppName()
+	if name == "" {
+		return nil, ErrNoAppName
+	}
+
+	return App(name)
+}
+
+// Name returns the name of the application h is bound to.
+func (h *Handle) Name() string {
+	return h.name
+}
+
+// DNS returns the instance of DNS h uses.
+func (h *Handle) DNS() DNS {
+	return h.dns
+}
+
+// Regions returns the regions the application is deployed to.
+func (h *Handle) Regions(ctx context.Context) ([]string, error) {
+	return h.dns.Regions(ctx, h.name)
+}
+
+// Instances returns the IPv6 addresses for the instances of the application in
+// the given region.
+//
+// Should the given region be empty, Instances returns all of the instances of
+// the application.
+func (h *Handle) Instances(ctx context.Context, region string) ([]net.IP, error) {
+	return h.dns.Instances(ctx, h.name, region)
+}
+
+// Nearest returns the IPv6 addresses of the n instances of the application
+// which are nearest to the local instance.
+//
+// Should the instance of DNS h uses not implement NearestResolver, Nearest
+// fails with ErrUnsupported.
+func (h *Handle) Nearest(ctx context.Context, n int) ([]net.IP, error) {
+	return nearestOf(ctx, h.dns, h.name, n)
+}
+
+// Machines returns the machines of the application.
+//
+// Should the instance of DNS h uses not implement MachineResolver, Machines
+// fails with ErrUnsupported.
+func (h *Handle) Machines(ctx context.Context) ([]Machine, error) {
+	return machinesOf(ctx, h.dns, h.name)
+}
+
+// Flycast returns the Flycast addresses of the application.
+//
+// Should the instance of DNS h uses not implement FlycastResolver, Flycast
+// fails with ErrUnsupported.
+func (h *Handle) Flycast(ctx context.Context) ([]net.IP, error) {
+	return flycastOf(ctx, h.dns, h.name)
+}
+
+// Watch polls, at the given interval, for the instances of the application in
+// the given region (or all of them, should region be empty).
+//
+// Refer to the package-level Watch for the details.
+func (h *Handle) Watch(ctx context.Context, region string, interval time.Duration) <-chan Membership {
+	return WatchOf(ctx, h.dns, h.name, region, interval)
+}
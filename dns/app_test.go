@@ -0,0 +1,120 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/azazeal/fly/dns/dnstest"
+	"github.com/azazeal/fly/env"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestValidateAppName(t *testing.T) {
+	valid := []string{
+		"a",
+		"app1",
+		"my-app",
+		"0app",
+		strings.Repeat("a", 63),
+	}
+	for _, name := range valid {
+		testutil.AssertEqual(t, nil, ValidateAppName(name))
+	}
+
+	invalid := []string{
+		"",
+		"-app",
+		"app-",
+		"App",
+		"my_app",
+		"my.app",
+		"apé",
+		strings.Repeat("a", 64),
+	}
+	for _, name := range invalid {
+		if err := ValidateAppName(name); !errors.Is(err, ErrInvalidAppName) {
+			t.Errorf("expected %q to be invalid, got %v", name, err)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	s := dnstest.NewServer(&dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "app1",
+				Instances: []dnstest.Instance{
+					{ID: "1781973f34d089", Region: "iad", IP: testutil.ParseIP(t, "fdaa::1")},
+					{ID: "e2865093a38586", Region: "ams", IP: testutil.ParseIP(t, "fdaa::2")},
+					{ID: "3d8d9e5b7e4289", Region: "ams", IP: testutil.ParseIP(t, "fdaa::3")},
+				},
+			},
+		},
+		Region: "ams",
+	})
+	t.Cleanup(s.Close)
+	t.Cleanup(stub(&Client{Addr: s.Addr}))
+
+	h, err := App("app1")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, "app1", h.Name())
+
+	ctx := context.TODO()
+
+	regions, err := h.Regions(ctx)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []string{"ams", "iad"}, regions)
+
+	ips, err := h.Instances(ctx, "iad")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []net.IP{testutil.ParseIP(t, "fdaa::1")}, ips)
+
+	nearest, err := h.Nearest(ctx, 2)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []net.IP{
+		testutil.ParseIP(t, "fdaa::2"),
+		testutil.ParseIP(t, "fdaa::3"),
+	}, nearest)
+
+	_, err = h.Nearest(ctx, 0)
+	testutil.AssertEqual(t, false, err == nil)
+
+	machines, err := h.Machines(ctx)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []Machine{
+		{ID: "1781973f34d089", Region: "iad"},
+		{ID: "e2865093a38586", Region: "ams"},
+		{ID: "3d8d9e5b7e4289", Region: "ams"},
+	}, machines)
+
+	_, err = App("App1")
+	testutil.AssertEqual(t, true, errors.Is(err, ErrInvalidAppName))
+}
+
+func TestHandleUnsupported(t *testing.T) {
+	// embedding hides the methods wrapper implements beyond DNS
+	h, err := NewHandle(struct{ DNS }{New(nil)}, "app1")
+	testutil.AssertEqual(t, nil, err)
+
+	_, err = h.Nearest(context.TODO(), 2)
+	testutil.AssertEqual(t, true, errors.Is(err, ErrUnsupported))
+
+	_, err = h.Machines(context.TODO())
+	testutil.AssertEqual(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestSelf(t *testing.T) {
+	t.Setenv(env.AppNameKey, "app1")
+
+	h, err := Self()
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, "app1", h.Name())
+
+	t.Setenv(env.AppNameKey, "")
+
+	_, err = Self()
+	testutil.AssertEqual(t, ErrNoAppName, err)
+}
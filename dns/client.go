@@ -0,0 +1,351 @@
+package dns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/azazeal/fly/internal/dnswire"
+)
+
+// TTLResolver wraps the functionality of resolvers that expose the TTLs of the
+// records they return.
+//
+// Client implements TTLResolver.
+type TTLResolver interface {
+	Resolver
+
+	// LookupTXTRecords returns the DNS TXT records, along with their TTLs, for
+	// the given domain name.
+	LookupTXTRecords(ctx context.Context, name string) ([]TXTRecord, error)
+
+	// LookupIPRecords returns the IP address records, along with their TTLs,
+	// for the given host and network. network must be one of "ip", "ip4" or
+	// "ip6".
+	LookupIPRecords(ctx context.Context, network, host string) ([]IPRecord, error)
+}
+
+// IPRecord wraps an A or AAAA record.
+type IPRecord struct {
+	IP  net.IP
+	TTL time.Duration
+}
+
+// TXTRecord wraps a TXT record.
+type TXTRecord struct {
+	Text string
+	TTL  time.Duration
+}
+
+// Client implements a dependency-free DNS client which queries a single
+// nameserver over UDP, falling back to TCP for truncated responses.
+//
+// Names are treated as fully-qualified; no search domains are applied.
+//
+// The zero value for Client is ready to use.
+type Client struct {
+	// Addr denotes the address of the nameserver. Should Addr be empty,
+	// DefaultNameserver is used.
+	Addr string
+
+	// Timeout denotes the amount of time a single exchange with the
+	// nameserver may take. Should Timeout be zero, DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+var _ TTLResolver = (*Client)(nil)
+
+// LookupTXT implements Resolver for Client.
+func (c *Client) LookupTXT(ctx context.Context, name string) (txts []string, err error) {
+	var records []TXTRecord
+	if records, err = c.LookupTXTRecords(ctx, name); err == nil {
+		for _, r := range records {
+			txts = append(txts, r.Text)
+		}
+	}
+
+	return
+}
+
+// LookupIP implements Resolver for Client.
+func (c *Client) LookupIP(ctx context.Context, network, host string) (ips []net.IP, err error) {
+	var records []IPRecord
+	if records, err = c.LookupIPRecords(ctx, network, host); err == nil {
+		for _, r := range records {
+			ips = append(ips, r.IP)
+		}
+	}
+
+	return
+}
+
+// LookupTXTRecords implements TTLResolver for Client.
+func (c *Client) LookupTXTRecords(ctx context.Context, name string) (records []TXTRecord, err error) {
+	var answers []dnswire.Resource
+	if answers, err = c.lookup(ctx, name, dnswire.TypeTXT); err != nil {
+		return
+	}
+
+	for i := range answers {
+		var text string
+		if text, err = answers[i].Text(); err != nil {
+			return nil, c.error(name, err.Error())
+		}
+
+		records = append(records, TXTRecord{
+			Text: text,
+			TTL:  ttl(answers[i].TTL),
+		})
+	}
+
+	return
+}
+
+// LookupIPRecords implements TTLResolver for Client.
+func (c *Client) LookupIPRecords(ctx context.Context, network, host string) (records []IPRecord, err error) {
+	var types []uint16
+	switch network {
+	case "ip":
+		types = []uint16{dnswire.TypeAAAA, dnswire.TypeA}
+	case "ip4":
+		types = []uint16{dnswire.TypeA}
+	case "ip6":
+		types = []uint16{dnswire.TypeAAAA}
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	var firstErr error
+	for _, typ := range types {
+		answers, err := c.lookup(ctx, host, typ)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		for i := range answers {
+			records = append(records, IPRecord{
+				IP:  answers[i].IP(),
+				TTL: ttl(answers[i].TTL),
+			})
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, firstErr
+	}
+
+	return records, nil
+}
+
+// lookup returns the answers of the given type for the given name. It never
+// returns an empty set of answers without also returning an error.
+func (c *Client) lookup(ctx context.Context, name string, typ uint16) ([]dnswire.Resource, error) {
+	fqdn := name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+
+	res, err := c.exchange(ctx, fqdn, typ)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) {
+			return nil, dnsErr
+		}
+
+		var ne net.Error
+		timeout := errors.As(err, &ne) && ne.Timeout() ||
+			errors.Is(err, context.DeadlineExceeded)
+
+		return nil, &net.DNSError{
+			Err:       err.Error(),
+			Name:      name,
+			Server:    c.addr(),
+			IsTimeout: timeout,
+		}
+	}
+
+	switch res.RCode {
+	case dnswire.RCodeSuccess:
+		break
+	case dnswire.RCodeNameError:
+		return nil, c.notFound(name)
+	default:
+		return nil, c.error(name, "server misbehaving")
+	}
+
+	var answers []dnswire.Resource
+	for _, a := range res.Answers {
+		if a.Type == typ && a.Class == dnswire.ClassINET {
+			answers = append(answers, a)
+		}
+	}
+
+	if len(answers) == 0 {
+		return nil, c.notFound(name)
+	}
+
+	return answers, nil
+}
+
+func (c *Client) exchange(ctx context.Context, fqdn string, typ uint16) (*dnswire.Message, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	query := &dnswire.Message{
+		Header: dnswire.Header{
+			ID:               binary.BigEndian.Uint16(id[:]),
+			RecursionDesired: true,
+		},
+		Questions: []dnswire.Question{
+			{Name: fqdn, Type: typ, Class: dnswire.ClassINET},
+		},
+	}
+
+	req, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.roundTrip(ctx, "udp", req, query)
+	if err == nil && res.Truncated {
+		res, err = c.roundTrip(ctx, "tcp", req, query)
+	}
+
+	return res, err
+}
+
+func (c *Client) roundTrip(ctx context.Context, network string, req []byte, query *dnswire.Message) (*dnswire.Message, error) {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, network, c.addr())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// unblock pending I/O on context cancellation
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	if network == "udp" {
+		return udpRoundTrip(conn, req, query)
+	}
+
+	return tcpRoundTrip(conn, req, query)
+}
+
+func udpRoundTrip(conn net.Conn, req []byte, query *dnswire.Message) (*dnswire.Message, error) {
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0xffff)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := dnswire.Parse(buf[:n])
+		if err != nil || !matches(query, res) {
+			continue // ignore stray & malformed datagrams
+		}
+
+		return res, nil
+	}
+}
+
+func tcpRoundTrip(conn net.Conn, req []byte, query *dnswire.Message) (*dnswire.Message, error) {
+	buf := make([]byte, 2, 2+len(req))
+	binary.BigEndian.PutUint16(buf, uint16(len(req)))
+	if _, err := conn.Write(append(buf, req...)); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint16(buf))
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, err
+	}
+
+	res, err := dnswire.Parse(data)
+	if err != nil {
+		return nil, err
+	} else if !matches(query, res) {
+		return nil, errors.New("response does not match query")
+	}
+
+	return res, nil
+}
+
+func matches(query, res *dnswire.Message) bool {
+	if !res.Response || res.ID != query.ID || len(res.Questions) != 1 {
+		return false
+	}
+
+	q, r := query.Questions[0], res.Questions[0]
+
+	return q.Type == r.Type && q.Class == r.Class && strings.EqualFold(q.Name, r.Name)
+}
+
+func (c *Client) addr() string {
+	if c.Addr == "" {
+		return DefaultNameserver
+	}
+
+	return c.Addr
+}
+
+func (c *Client) notFound(name string) error {
+	return &net.DNSError{
+		Err:        "no such host",
+		Name:       name,
+		Server:     c.addr(),
+		IsNotFound: true,
+	}
+}
+
+func (c *Client) error(name, msg string) error {
+	return &net.DNSError{
+		Err:    msg,
+		Name:   name,
+		Server: c.addr(),
+	}
+}
+
+func ttl(v uint32) time.Duration {
+	return time.Duration(v) * time.Second
+}
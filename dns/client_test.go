@@ -0,0 +1,215 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/internal/dnswire"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestClientLookupIPRecords(t *testing.T) {
+	const ip = "fdaa:0:22b7:a7b:abd:aa3c:6498:2"
+
+	c := &Client{
+		Addr: serve(t, func(q *dnswire.Message) *dnswire.Message {
+			res := reply(q)
+			if q.Questions[0].Type == dnswire.TypeAAAA {
+				res.Answers = append(res.Answers,
+					dnswire.NewIP(q.Questions[0].Name, 42, testutil.ParseIP(t, ip)))
+			}
+
+			return res
+		}),
+	}
+
+	got, err := c.LookupIPRecords(context.TODO(), "ip", "global.app.internal")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []IPRecord{
+		{IP: testutil.ParseIP(t, ip), TTL: 42 * time.Second},
+	}, got)
+
+	_, err = c.LookupIP(context.TODO(), "ip4", "global.app.internal")
+	assertNotFound(t, err)
+}
+
+func TestClientLookupTXTRecords(t *testing.T) {
+	c := &Client{
+		Addr: serve(t, func(q *dnswire.Message) *dnswire.Message {
+			res := reply(q)
+			if name := q.Questions[0].Name; name == "_apps.internal." {
+				res.Answers = append(res.Answers,
+					dnswire.NewTXT(name, 5, "app1,app2"),
+					dnswire.NewTXT(name, 7, "app3"))
+			} else {
+				res.RCode = dnswire.RCodeNameError
+			}
+
+			return res
+		}),
+	}
+
+	got, err := c.LookupTXTRecords(context.TODO(), "_apps.internal")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []TXTRecord{
+		{Text: "app1,app2", TTL: 5 * time.Second},
+		{Text: "app3", TTL: 7 * time.Second},
+	}, got)
+
+	_, err = c.LookupTXT(context.TODO(), "_peer.internal")
+	assertNotFound(t, err)
+}
+
+func TestClientFallsBackToTCP(t *testing.T) {
+	c := &Client{
+		Addr: serve(t, func(q *dnswire.Message) *dnswire.Message {
+			res := reply(q)
+			res.Answers = append(res.Answers, dnswire.NewTXT(q.Questions[0].Name, 5, "tcp"))
+
+			return res
+		}),
+	}
+
+	got, err := New(c).Apps(context.TODO())
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []string{"tcp"}, got)
+}
+
+func TestClientServerFailure(t *testing.T) {
+	c := &Client{
+		Addr: serve(t, func(q *dnswire.Message) *dnswire.Message {
+			res := reply(q)
+			res.RCode = dnswire.RCodeServerFailure
+
+			return res
+		}),
+	}
+
+	_, err := c.LookupTXT(context.TODO(), "_apps.internal")
+
+	var dnsErr *net.DNSError
+	testutil.AssertEqual(t, true, errors.As(err, &dnsErr))
+	testutil.AssertEqual(t, false, dnsErr.IsNotFound)
+}
+
+func TestClientTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed listening: %v", err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+
+	c := &Client{
+		Addr:    pc.LocalAddr().String(),
+		Timeout: 50 * time.Millisecond,
+	}
+
+	_, err = c.LookupTXT(context.TODO(), "_apps.internal")
+
+	var dnsErr *net.DNSError
+	testutil.AssertEqual(t, true, errors.As(err, &dnsErr))
+	testutil.AssertEqual(t, true, dnsErr.IsTimeout)
+}
+
+func assertNotFound(t *testing.T, err error) {
+	t.Helper()
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func reply(q *dnswire.Message) *dnswire.Message {
+	return &dnswire.Message{
+		Header: dnswire.Header{
+			ID:       q.ID,
+			Response: true,
+		},
+		Questions: q.Questions,
+	}
+}
+
+// serve starts a nameserver which answers queries via fn, on both UDP & TCP.
+//
+// Responses sent over UDP which contain TXT records with the text "tcp" are
+// truncated.
+func serve(t *testing.T, fn func(*dnswire.Message) *dnswire.Message) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed listening: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	pc, err := net.ListenPacket("udp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed listening: %v", err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+
+	go func() {
+		buf := make([]byte, dnswire.MaxUDPSize)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			q, err := dnswire.Parse(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			res := fn(q)
+			for _, a := range res.Answers {
+				if txt, _ := a.Text(); txt == "tcp" {
+					res = reply(q)
+					res.Truncated = true
+				}
+			}
+
+			data, _ := res.Pack()
+			_, _ = pc.WriteTo(data, addr)
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				var l [2]byte
+				if _, err := io.ReadFull(conn, l[:]); err != nil {
+					return
+				}
+				buf := make([]byte, binary.BigEndian.Uint16(l[:]))
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					return
+				}
+
+				q, err := dnswire.Parse(buf)
+				if err != nil {
+					return
+				}
+
+				data, _ := fn(q).Pack()
+				binary.BigEndian.PutUint16(l[:], uint16(len(data)))
+				_, _ = conn.Write(append(l[:], data...))
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
@@ -0,0 +1,149 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Addressing denotes the way a Dialer reaches the instances of an application.
+type Addressing int
+
+const (
+	// Direct denotes that the instances of an application are dialed directly,
+	// via their .internal addresses.
+	Direct Addressing = iota
+
+	// ViaFlycast denotes that an application is dialed via its Flycast
+	// addresses, letting fly-proxy balance the connections across its
+	// instances.
+	ViaFlycast
+)
+
+// String implements fmt.Stringer for Addressing.
+func (a Addressing) String() string {
+	switch a {
+	case Direct:
+		return "direct"
+	case ViaFlycast:
+		return "flycast"
+	default:
+		return "unknown"
+	}
+}
+
+// Dialer dials applications by name.
+//
+// Dialer recognizes addresses whose host is either <app>.internal or
+// <app>.flycast. The former are dialed in the way Addressing dictates while the
+// latter are always dialed via Flycast. Any other address is dialed as is.
+//
+// The resolved addresses are tried in order until one of them accepts the
+// connection.
+type Dialer struct {
+	// DNS denotes the instance of DNS applications are resolved with. Should
+	// DNS be nil, the package-level functions are used.
+	//
+	// Dialing via Flycast fails with ErrUnsupported should DNS not implement
+	// FlycastResolver.
+	DNS DNS
+
+	// Dialer denotes the dialer connections are established with. Should
+	// Dialer be nil, the zero value of net.Dialer is used.
+	Dialer *net.Dialer
+
+	// Addressing, when set, returns the way the named application should be
+	// reached when dialed via its .internal name. Should Addressing be nil,
+	// .internal names are dialed directly.
+	Addressing func(appName string) Addressing
+}
+
+// DialContext connects to address on the named network using ctx.
+//
+// DialContext may be used as the DialContext field of http.Transport.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return d.dialer().DialContext(ctx, network, address)
+	}
+
+	appName, addressing, ok := d.target(host)
+	if !ok {
+		return d.dialer().DialContext(ctx, network, address)
+	}
+
+	var ips []net.IP
+	if addressing == ViaFlycast {
+		ips, err = flycastOf(ctx, d.dns(), appName)
+	} else {
+		ips, err = d.dns().Instances(ctx, appName, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = &net.DNSError{
+		Err:        "no such host",
+		Name:       host,
+		IsNotFound: true,
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = d.dialer().DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, nil
+		} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+	}
+
+	return nil, err
+}
+
+// Transport returns a clone of http.DefaultTransport which dials via d.
+func (d *Dialer) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = d.DialContext
+
+	return t
+}
+
+// target reports the application host refers to, if any, along with the
+// way it should be reached.
+func (d *Dialer) target(host string) (appName string, addressing Addressing, ok bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	name, tld, _ := strings.Cut(host, ".")
+	if ValidateAppName(name) != nil {
+		return
+	}
+
+	switch tld {
+	case "internal":
+		appName, ok = name, true
+		if d.Addressing != nil {
+			addressing = d.Addressing(appName)
+		}
+	case "flycast":
+		appName, addressing, ok = name, ViaFlycast, true
+	}
+
+	return
+}
+
+func (d *Dialer) dns() DNS {
+	if d.DNS == nil {
+		return global
+	}
+
+	return d.DNS
+}
+
+func (d *Dialer) dialer() *net.Dialer {
+	if d.Dialer == nil {
+		return &net.Dialer{}
+	}
+
+	return d.Dialer
+}
@@ -0,0 +1,141 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestDialer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	var lookups []string // lookups happen on the dialing goroutine
+	d := New(&mockResolver{
+		lookupIP: func(_ context.Context, _, host string) ([]net.IP, error) {
+			lookups = append(lookups, host)
+
+			if host == "app3.flycast" || host == "global.app3.internal" {
+				return nil, staticNotFound(host)
+			}
+
+			// nothing listens on the first address
+			return []net.IP{
+				testutil.ParseIP(t, "127.0.0.2"),
+				testutil.ParseIP(t, "127.0.0.1"),
+			}, nil
+		},
+	})
+
+	dialer := &Dialer{
+		DNS: d,
+		Addressing: func(appName string) Addressing {
+			if appName == "app2" {
+				return ViaFlycast
+			}
+
+			return Direct
+		},
+	}
+
+	cases := []struct {
+		address string
+		lookup  string
+		err     bool
+	}{
+		0: {"app1.internal:" + port, "global.app1.internal", false},
+		1: {"app2.internal:" + port, "app2.flycast", false},
+		2: {"app1.flycast:" + port, "app1.flycast", false},
+		3: {"APP1.Internal.:" + port, "global.app1.internal", false},
+		4: {"127.0.0.1:" + port, "", false},
+		5: {"app3.flycast:" + port, "app3.flycast", true},
+		6: {"app3.internal:" + port, "global.app3.internal", true},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			lookups = nil
+
+			conn, err := dialer.DialContext(context.TODO(), "tcp", kase.address)
+			if kase.err {
+				var de *net.DNSError
+				if !errors.As(err, &de) || !de.IsNotFound {
+					t.Errorf("expected a not found error, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else {
+				testutil.AssertEqual(t, "127.0.0.1:"+port, conn.RemoteAddr().String())
+				_ = conn.Close()
+			}
+
+			var want []string
+			if kase.lookup != "" {
+				want = []string{kase.lookup}
+			}
+			testutil.AssertEqual(t, want, lookups)
+		})
+	}
+}
+
+func TestDialerWithoutFlycast(t *testing.T) {
+	// embedding hides the methods wrapper implements beyond DNS
+	d := &Dialer{DNS: struct{ DNS }{New(nil)}}
+
+	_, err := d.DialContext(context.TODO(), "tcp", "app1.flycast:80")
+	testutil.AssertEqual(t, true, errors.Is(err, ErrUnsupported))
+}
+
+func TestDialerTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	d := New(&mockResolver{
+		lookupIP: func(context.Context, string, string) ([]net.IP, error) {
+			return []net.IP{testutil.ParseIP(t, "127.0.0.1")}, nil
+		},
+	})
+
+	client := &http.Client{Transport: (&Dialer{DNS: d}).Transport()}
+
+	res, err := client.Get("http://app1.flycast:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	testutil.AssertEqual(t, "ok", testutil.ReadAll(t, res.Body))
+}
+
+func TestAddressingString(t *testing.T) {
+	testutil.AssertEqual(t, "direct", Direct.String())
+	testutil.AssertEqual(t, "flycast", ViaFlycast.String())
+	testutil.AssertEqual(t, "unknown", Addressing(-1).String())
+}
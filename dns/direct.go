@@ -0,0 +1,142 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultNameserver denotes the address of fly's internal nameserver.
+const DefaultNameserver = "[fdaa::3]:53"
+
+// Default values of the settings DirectResolver uses.
+const (
+	// DefaultNetwork denotes the default network queries are sent over.
+	DefaultNetwork = "udp"
+
+	// DefaultDialTimeout denotes the default amount of time dialing the
+	// nameserver may take.
+	DefaultDialTimeout = 2 * time.Second
+
+	// DefaultTimeout denotes the default amount of time a single lookup may
+	// take.
+	DefaultTimeout = 5 * time.Second
+)
+
+// DirectOption configures the Resolver DirectResolver returns.
+type DirectOption func(*directConfig)
+
+// WithNetwork sets the network ("udp" or "tcp", case-insensitively) queries
+// are sent over. Lookups via a Resolver configured with any other network fail
+// with a net.UnknownNetworkError.
+//
+// Responses which are truncated when network is "udp" are retried over "tcp".
+func WithNetwork(network string) DirectOption {
+	return func(cfg *directConfig) {
+		cfg.network = strings.ToLower(network)
+	}
+}
+
+// WithDialTimeout sets the amount of time dialing the nameserver may take.
+func WithDialTimeout(d time.Duration) DirectOption {
+	return func(cfg *directConfig) {
+		cfg.dialTimeout = d
+	}
+}
+
+// WithTimeout sets the amount of time a single lookup may take.
+//
+// A non-positive d disables the timeout; lookups then only honor the deadline
+// of their context.
+func WithTimeout(d time.Duration) DirectOption {
+	return func(cfg *directConfig) {
+		cfg.timeout = d
+	}
+}
+
+type directConfig struct {
+	addr        string
+	network     string
+	dialTimeout time.Duration
+	timeout     time.Duration
+}
+
+// NewDirect returns an instance of DNS that queries the nameserver at addr
+// directly, bypassing the system's resolver configuration.
+//
+// Should addr be empty, DefaultNameserver is used.
+func NewDirect(addr string, opts ...DirectOption) DNS {
+	return New(DirectResolver(addr, opts...))
+}
+
+// DirectResolver returns a Resolver that queries the nameserver at addr
+// directly, bypassing the system's resolver configuration.
+//
+// Should addr be empty, DefaultNameserver is used.
+func DirectResolver(addr string, opts ...DirectOption) Resolver {
+	cfg := &directConfig{
+		addr:        addr,
+		network:     DefaultNetwork,
+		dialTimeout: DefaultDialTimeout,
+		timeout:     DefaultTimeout,
+	}
+	if cfg.addr == "" {
+		cfg.addr = DefaultNameserver
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial:     cfg.dial,
+	}
+
+	if cfg.timeout <= 0 {
+		return r
+	}
+
+	return &timeoutResolver{
+		Resolver: r,
+		timeout:  cfg.timeout,
+	}
+}
+
+func (cfg *directConfig) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	switch cfg.network {
+	case "tcp":
+		network = "tcp"
+	case "udp":
+		// network is either udp or, for truncated responses, tcp
+	default:
+		return nil, net.UnknownNetworkError(cfg.network)
+	}
+
+	d := net.Dialer{
+		Timeout: cfg.dialTimeout,
+	}
+
+	return d.DialContext(ctx, network, cfg.addr)
+}
+
+type timeoutResolver struct {
+	*net.Resolver
+
+	timeout time.Duration
+}
+
+func (tr *timeoutResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tr.timeout)
+	defer cancel()
+
+	return tr.Resolver.LookupTXT(ctx, name)
+}
+
+func (tr *timeoutResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, tr.timeout)
+	defer cancel()
+
+	return tr.Resolver.LookupIP(ctx, network, host)
+}
@@ -0,0 +1,94 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestDirectResolverDefaults(t *testing.T) {
+	tr, ok := DirectResolver("").(*timeoutResolver)
+	if !ok {
+		t.Fatal("expected a timeoutResolver")
+	}
+
+	testutil.AssertEqual(t, DefaultTimeout, tr.timeout)
+	testutil.AssertEqual(t, true, tr.PreferGo)
+}
+
+func TestDirectResolverWithoutTimeout(t *testing.T) {
+	_, ok := DirectResolver("", WithTimeout(0)).(*net.Resolver)
+	testutil.AssertEqual(t, true, ok)
+}
+
+func TestDirectResolverDialsUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed listening: %v", err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+
+	r := DirectResolver(pc.LocalAddr().String(), WithTimeout(0)).(*net.Resolver)
+
+	conn, err := r.Dial(context.TODO(), "udp", "192.0.2.1:53")
+	testutil.AssertEqual(t, nil, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	testutil.AssertEqual(t, "udp", conn.RemoteAddr().Network())
+	testutil.AssertEqual(t, pc.LocalAddr().String(), conn.RemoteAddr().String())
+}
+
+func TestDirectResolverDialsTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed listening: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	r := DirectResolver(l.Addr().String(), WithNetwork("TCP"), WithTimeout(0)).(*net.Resolver)
+
+	conn, err := r.Dial(context.TODO(), "udp", "192.0.2.1:53")
+	testutil.AssertEqual(t, nil, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	testutil.AssertEqual(t, "tcp", conn.RemoteAddr().Network())
+	testutil.AssertEqual(t, l.Addr().String(), conn.RemoteAddr().String())
+}
+
+func TestDirectResolverRejectsUnknownNetworks(t *testing.T) {
+	r := DirectResolver("", WithNetwork("tcp4"), WithTimeout(0)).(*net.Resolver)
+
+	_, err := r.Dial(context.TODO(), "udp", "192.0.2.1:53")
+	testutil.AssertEqual(t, net.UnknownNetworkError("tcp4"), err)
+
+	_, err = r.LookupTXT(context.TODO(), "_apps.internal")
+	if err == nil || !strings.Contains(err.Error(), "unknown network tcp4") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDirectResolverTimeout(t *testing.T) {
+	// a nameserver which never responds
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed listening: %v", err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+
+	const timeout = 50 * time.Millisecond
+	d := NewDirect(pc.LocalAddr().String(), WithTimeout(timeout))
+
+	start := time.Now()
+	_, err = d.Apps(context.TODO())
+
+	var dnsErr *net.DNSError
+	testutil.AssertEqual(t, true, errors.As(err, &dnsErr))
+	if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Errorf("lookup took %s", elapsed)
+	}
+}
@@ -4,10 +4,12 @@ package dns
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Resolver wraps the functionality that instances of DNS rely on.
@@ -25,10 +27,55 @@ type Resolver interface {
 
 var global = New(net.DefaultResolver)
 
-// New returns an instance of DNS that uses the given Resolver.
-func New(r Resolver) DNS {
-	return &wrapper{
-		Resolver: r,
+// SetDefault sets the instance of DNS the package-level functions use.
+//
+// SetDefault is meant to be called during initialization (e.g. with the result
+// of NewAuto) and is not safe for concurrent use with the rest of the
+// package-level functions.
+func SetDefault(d DNS) {
+	global = d
+}
+
+// Default returns the instance of DNS the package-level functions use.
+func Default() DNS {
+	return global
+}
+
+// New returns an instance of DNS that uses the given Resolver, configured by
+// the given options.
+func New(r Resolver, opts ...Option) DNS {
+	w := &wrapper{
+		Resolver:    r,
+		parallelism: DefaultParallelism,
+		minBackoff:  DefaultMinBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+
+		privateIPSources: DefaultPrivateIPSources(),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Option configures the instance of DNS New returns.
+type Option func(*wrapper)
+
+// DefaultParallelism denotes the default maximum number of concurrent lookups
+// the functions which fan out (e.g. Snapshot) perform.
+const DefaultParallelism = 8
+
+// WithParallelism sets the maximum number of concurrent lookups the functions
+// which fan out (e.g. Snapshot) perform on behalf of the instance. Values less
+// than 1 are treated as 1.
+func WithParallelism(n int) Option {
+	return func(w *wrapper) {
+		if n < 1 {
+			n = 1
+		}
+		w.parallelism = n
 	}
 }
 
@@ -51,17 +98,69 @@ type DNS interface {
 	Peers(ctx context.Context) ([]string, error)
 
 	// Peer returns the IPv6 address of the named wireguard peer.
+	//
+	// Should the peer have no address, Peer returns a *net.DNSError which
+	// reports IsNotFound.
 	Peer(ctx context.Context, name string) (net.IP, error)
 
 	// PrivateIP returns the IPv6 address of the local instance.
 	PrivateIP(ctx context.Context) (net.IP, error)
 }
 
+// ErrUnsupported is returned by the functions which rely on lookups the
+// instance of DNS they use does not support (e.g. ProcessResolver).
+var ErrUnsupported = errors.New("dns: lookup not supported")
+
+func unsupported(lookup string) error {
+	return fmt.Errorf("%w: %s", ErrUnsupported, lookup)
+}
+
+// ProcessResolver is implemented by the instances of DNS which, like the ones
+// New returns, look up the instances of process groups.
+type ProcessResolver interface {
+	// ProcessInstances returns the IPv6 addresses for the instances of the
+	// named application which belong to the given process group.
+	ProcessInstances(ctx context.Context, appName, group string) ([]net.IP, error)
+}
+
+// NearestResolver is implemented by the instances of DNS which, like the ones
+// New returns, look up the instances nearest to the local one.
+type NearestResolver interface {
+	// Nearest returns the IPv6 addresses of the n instances of the named
+	// application which are nearest to the local instance.
+	Nearest(ctx context.Context, appName string, n int) ([]net.IP, error)
+}
+
+// MachineResolver is implemented by the instances of DNS which, like the ones
+// New returns, look up the machines of applications.
+type MachineResolver interface {
+	// Machines returns the machines of the named application.
+	Machines(ctx context.Context, appName string) ([]Machine, error)
+}
+
+// FlycastResolver is implemented by the instances of DNS which, like the ones
+// New returns, look up the Flycast addresses of applications.
+type FlycastResolver interface {
+	// Flycast returns the Flycast addresses of the named application; that is
+	// the private addresses through which fly-proxy load balances requests
+	// across its instances.
+	Flycast(ctx context.Context, appName string) ([]net.IP, error)
+}
+
 type wrapper struct {
 	Resolver
 
-	privateIPMu sync.Mutex // protects privateIP
-	privateIP   net.IP
+	parallelism int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+
+	privateIPSources []PrivateIPSource
+	privateIPTTL     time.Duration
+
+	privateIPMu     sync.Mutex // protects the fields below
+	privateIP       net.IP
+	privateIPSource PrivateIPSource
+	privateIPAt     time.Time
 }
 
 func (w *wrapper) splitTXT(ctx context.Context, name string) (tokens []string, err error) {
@@ -87,40 +186,71 @@ func (w *wrapper) Instances(ctx context.Context, appName, region string) ([]net.
 	return w.LookupIP(ctx, "ip6", region+"."+appName+".internal")
 }
 
-func (w *wrapper) Apps(ctx context.Context) ([]string, error) {
-	return w.splitTXT(ctx, "_apps.internal")
+func (w *wrapper) ProcessInstances(ctx context.Context, appName, group string) ([]net.IP, error) {
+	return w.LookupIP(ctx, "ip6", group+".process."+appName+".internal")
 }
 
-func (w *wrapper) Peers(ctx context.Context) ([]string, error) {
-	return w.splitTXT(ctx, "_peer.internal")
+func (w *wrapper) Nearest(ctx context.Context, appName string, n int) ([]net.IP, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("dns: invalid number of nearest instances: %d", n)
+	}
+
+	return w.LookupIP(ctx, "ip6", fmt.Sprintf("top%d.nearest.of.%s.internal", n, appName))
 }
 
-func (w *wrapper) Peer(ctx context.Context, name string) (ip net.IP, err error) {
-	host := fmt.Sprintf("%s._peer.internal", name)
+// Machine wraps the properties of a machine.
+type Machine struct {
+	// ID denotes the ID of the machine.
+	ID string
 
-	var ips []net.IP
-	if ips, err = w.LookupIP(ctx, "ip6", host); err == nil && len(ips) > 0 {
-		ip = ips[0]
+	// Region denotes the region the machine runs in.
+	Region string
+}
+
+func (w *wrapper) Machines(ctx context.Context, appName string) (machines []Machine, err error) {
+	var tokens []string
+	if tokens, err = w.splitTXT(ctx, "vms."+appName+".internal"); err != nil {
+		return
+	}
+
+	for _, token := range tokens {
+		if id, region, ok := strings.Cut(strings.TrimSpace(token), " "); ok {
+			machines = append(machines, Machine{ID: id, Region: region})
+		}
 	}
 
 	return
 }
 
-func (w *wrapper) PrivateIP(ctx context.Context) (ip net.IP, err error) {
-	w.privateIPMu.Lock()
-	defer w.privateIPMu.Unlock()
+func (w *wrapper) Flycast(ctx context.Context, appName string) ([]net.IP, error) {
+	return w.LookupIP(ctx, "ip6", appName+".flycast")
+}
+
+func (w *wrapper) Apps(ctx context.Context) ([]string, error) {
+	return w.splitTXT(ctx, "_apps.internal")
+}
 
-	if w.privateIP == nil {
-		const host = "fly-local-6pn"
+func (w *wrapper) Peers(ctx context.Context) ([]string, error) {
+	return w.splitTXT(ctx, "_peer.internal")
+}
+
+func (w *wrapper) Peer(ctx context.Context, name string) (ip net.IP, err error) {
+	host := fmt.Sprintf("%s._peer.internal", name)
 
-		var ips []net.IP
-		if ips, err = w.LookupIP(ctx, "ip6", host); err == nil && len(ips) > 0 {
-			w.privateIP = append(w.privateIP, ips[0]...)
+	var ips []net.IP
+	switch ips, err = w.LookupIP(ctx, "ip6", host); {
+	case err != nil:
+		break
+	case len(ips) == 0:
+		err = &net.DNSError{
+			Err:        "no such host",
+			Name:       host,
+			IsNotFound: true,
 		}
+	default:
+		ip = ips[0]
 	}
 
-	ip = append(ip, w.privateIP...)
-
 	return
 }
 
@@ -138,6 +268,74 @@ func Instances(ctx context.Context, appName, region string) ([]net.IP, error) {
 	return global.Instances(ctx, appName, region)
 }
 
+// ProcessInstances returns the IPv6 addresses for the instances of the named
+// application which belong to the given process group.
+//
+// Should the instance of DNS the package-level functions use not implement
+// ProcessResolver, ProcessInstances fails with ErrUnsupported.
+func ProcessInstances(ctx context.Context, appName, group string) ([]net.IP, error) {
+	return processInstancesOf(ctx, global, appName, group)
+}
+
+func processInstancesOf(ctx context.Context, d DNS, appName, group string) ([]net.IP, error) {
+	if pr, ok := d.(ProcessResolver); ok {
+		return pr.ProcessInstances(ctx, appName, group)
+	}
+
+	return nil, unsupported("ProcessInstances")
+}
+
+// Nearest returns the IPv6 addresses of the n instances of the named
+// application which are nearest to the local instance.
+//
+// Should the instance of DNS the package-level functions use not implement
+// NearestResolver, Nearest fails with ErrUnsupported.
+func Nearest(ctx context.Context, appName string, n int) ([]net.IP, error) {
+	return nearestOf(ctx, global, appName, n)
+}
+
+func nearestOf(ctx context.Context, d DNS, appName string, n int) ([]net.IP, error) {
+	if nr, ok := d.(NearestResolver); ok {
+		return nr.Nearest(ctx, appName, n)
+	}
+
+	return nil, unsupported("Nearest")
+}
+
+// Machines returns the machines of the named application.
+//
+// Should the instance of DNS the package-level functions use not implement
+// MachineResolver, Machines fails with ErrUnsupported.
+func Machines(ctx context.Context, appName string) ([]Machine, error) {
+	return machinesOf(ctx, global, appName)
+}
+
+func machinesOf(ctx context.Context, d DNS, appName string) ([]Machine, error) {
+	if mr, ok := d.(MachineResolver); ok {
+		return mr.Machines(ctx, appName)
+	}
+
+	return nil, unsupported("Machines")
+}
+
+// Flycast returns the Flycast addresses of the named application; that is the
+// private addresses through which fly-proxy load balances requests across its
+// instances.
+//
+// Should the instance of DNS the package-level functions use not implement
+// FlycastResolver, Flycast fails with ErrUnsupported.
+func Flycast(ctx context.Context, appName string) ([]net.IP, error) {
+	return flycastOf(ctx, global, appName)
+}
+
+func flycastOf(ctx context.Context, d DNS, appName string) ([]net.IP, error) {
+	if fr, ok := d.(FlycastResolver); ok {
+		return fr.Flycast(ctx, appName)
+	}
+
+	return nil, unsupported("Flycast")
+}
+
 // Apps returns the applications running in the current organization.
 func Apps(ctx context.Context) ([]string, error) {
 	return global.Apps(ctx)
@@ -149,11 +347,16 @@ func Peers(ctx context.Context) ([]string, error) {
 }
 
 // Peer returns the IPv6 address of the named wireguard peer.
+//
+// Should the peer have no address, Peer returns a *net.DNSError which reports
+// IsNotFound.
 func Peer(ctx context.Context, name string) (net.IP, error) {
 	return global.Peer(ctx, name)
 }
 
 // PrivateIP returns the IPv6 address of the local instance.
+//
+// PrivateIP is shorthand for LookupPrivateIP without the source.
 func PrivateIP(ctx context.Context) (net.IP, error) {
 	return global.PrivateIP(ctx)
 }
@@ -3,6 +3,7 @@ package dns
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"sort"
@@ -80,6 +81,30 @@ func TestAllInstances(t *testing.T) {
 	}, got)
 }
 
+func TestProcessInstances(t *testing.T) {
+	appName := token(t)
+
+	t.Cleanup(stub(&mockResolver{
+		lookupIP: func(_ context.Context, _, name string) ([]net.IP, error) {
+			if want := "worker.process." + appName + ".internal"; want != name {
+				return nil, fmt.Errorf("wrong name: want %q, have %q", want, name)
+			}
+
+			return []net.IP{net.ParseIP("fdaa:0:22b7:a7b:abd:aa3c:6498:2")}, nil
+		},
+	}))
+
+	got, err := ProcessInstances(context.TODO(), appName, "worker")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []net.IP{net.ParseIP("fdaa:0:22b7:a7b:abd:aa3c:6498:2")}, got)
+
+	// embedding hides the methods wrapper implements beyond DNS
+	SetDefault(struct{ DNS }{Default()})
+
+	_, err = ProcessInstances(context.TODO(), appName, "worker")
+	testutil.AssertEqual(t, true, errors.Is(err, ErrUnsupported))
+}
+
 func TestApps(t *testing.T) {
 	t.Cleanup(stub(&mockResolver{
 		lookupTXT: func(_ context.Context, name string) ([]string, error) {
@@ -166,6 +191,25 @@ func TestPeer(t *testing.T) {
 	testutil.AssertEqual(t, testutil.ParseIP(t, ip2), got)
 }
 
+func TestPeerNotFound(t *testing.T) {
+	peer := token(t)
+
+	t.Cleanup(stub(&mockResolver{
+		lookupIP: func(context.Context, string, string) ([]net.IP, error) {
+			return nil, nil
+		},
+	}))
+
+	got, err := Peer(context.TODO(), peer)
+	testutil.AssertEqual(t, net.IP(nil), got)
+
+	var de *net.DNSError
+	if !errors.As(err, &de) || !de.IsNotFound {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	testutil.AssertEqual(t, peer+"._peer.internal", de.Name)
+}
+
 func TestPrivateIP(t *testing.T) {
 	const (
 		ip1 = "fdaa:0:22b7:a7b:aa0:12a5:aacb:2"
@@ -205,9 +249,12 @@ func (mr *mockResolver) LookupIP(ctx context.Context, network, host string) ([]n
 	return mr.lookupIP(ctx, network, host)
 }
 
+// stub replaces the default instance with one which uses r. The instance only
+// determines the private IP via r, so that tests do not depend on the
+// environment (FLY_PRIVATE_IP) or the hosts file of the machine they run on.
 func stub(r Resolver) func() {
 	old := global
-	global = New(r)
+	global = New(r, WithPrivateIPSources(SourceDNS))
 
 	return func() { global = old }
 }
@@ -0,0 +1,573 @@
+// Package dnstest implements an in-process fake of fly's internal nameserver,
+// for use in tests and during local development.
+package dnstest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azazeal/fly/internal/dnswire"
+)
+
+// DefaultTTL denotes the TTL of the records a Server serves for topologies
+// that do not define one.
+const DefaultTTL = 5 * time.Second
+
+// Topology wraps the layout of an organization, as a Server presents it.
+type Topology struct {
+	// Apps denotes the applications running in the organization.
+	Apps []App
+
+	// Peers denotes the wireguard peers attached to the organization.
+	Peers []Peer
+
+	// LocalIP denotes the address fly-local-6pn resolves to.
+	LocalIP net.IP
+
+	// Region denotes the region queries are considered to originate from.
+	// top<n>.nearest.of.<app>.internal lists the instances of the region
+	// first, followed by the rest in the order they're defined in.
+	Region string
+
+	// TTL denotes the TTL of the records the Server serves. Should TTL be
+	// zero, DefaultTTL is used.
+	TTL time.Duration
+}
+
+// App wraps an application.
+type App struct {
+	// Name denotes the name of the application.
+	Name string
+
+	// Instances denotes the instances of the application.
+	Instances []Instance
+
+	// Flycast denotes the Flycast addresses of the application, served under
+	// <app>.flycast.
+	Flycast []net.IP
+}
+
+// Instance wraps an instance (machine) of an application.
+type Instance struct {
+	// ID denotes the ID of the machine. Instances without an ID are not
+	// listed under vms.<app>.internal.
+	ID string
+
+	// Region denotes the region the instance runs in.
+	Region string
+
+	// ProcessGroup denotes the process group the instance belongs to.
+	// Instances without a process group are not listed under
+	// <group>.process.<app>.internal.
+	ProcessGroup string
+
+	// IP denotes the 6PN address of the instance.
+	IP net.IP
+}
+
+// Peer wraps a wireguard peer.
+type Peer struct {
+	// Name denotes the name of the peer.
+	Name string
+
+	// IP denotes the 6PN address of the peer.
+	IP net.IP
+}
+
+// Failure denotes a failure a Server may be instructed to inject.
+type Failure int
+
+// The set of injectable failures.
+const (
+	// NoFailure denotes the absence of an injected failure.
+	NoFailure Failure = iota
+
+	// ServerFailure causes the Server to respond with SERVFAIL.
+	ServerFailure
+
+	// Refused causes the Server to respond with REFUSED.
+	Refused
+
+	// NameError causes the Server to respond with NXDOMAIN.
+	NameError
+
+	// Drop causes the Server to not respond at all.
+	Drop
+)
+
+// Server is a nameserver which serves the .internal zone of a Topology, over
+// both UDP and TCP, on the same local port.
+type Server struct {
+	// Addr denotes the address the Server listens to, in host:port form.
+	Addr string
+
+	pc net.PacketConn
+	l  net.Listener
+	wg sync.WaitGroup
+
+	connsMu sync.Mutex // protects conns & closed
+	conns   map[net.Conn]struct{}
+	closed  bool
+
+	mu       sync.RWMutex // protects the fields below
+	zone     *zone
+	ttl      uint32
+	latency  time.Duration
+	failures map[string]Failure
+}
+
+type zone struct {
+	records map[string]*records
+	nearest map[string][]net.IP // app name -> instances, nearest first
+}
+
+type records struct {
+	txt []string
+	ips []net.IP
+}
+
+// NewServer starts and returns a new Server which serves topo.
+//
+// The caller should call Close when finished, to shut it down.
+//
+// NewServer panics in case it fails listening.
+func NewServer(topo *Topology) *Server {
+	s, err := newServer()
+	if err != nil {
+		panic(fmt.Sprintf("dnstest: failed listening: %v", err))
+	}
+
+	s.SetTopology(topo)
+
+	s.wg.Add(2)
+	go s.serveUDP()
+	go s.serveTCP()
+
+	return s
+}
+
+func newServer() (*Server, error) {
+	const attempts = 10
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		var l net.Listener
+		if l, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+			continue
+		}
+
+		var pc net.PacketConn
+		if pc, err = net.ListenPacket("udp", l.Addr().String()); err != nil {
+			_ = l.Close() // the UDP port is taken; try another one
+
+			continue
+		}
+
+		return &Server{
+			Addr: l.Addr().String(),
+			pc:   pc,
+			l:    l,
+		}, nil
+	}
+
+	return nil, err
+}
+
+// Close shuts down s and blocks until all of its pending requests have
+// completed.
+func (s *Server) Close() {
+	_ = s.pc.Close()
+	_ = s.l.Close()
+
+	s.connsMu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.connsMu.Unlock()
+
+	s.wg.Wait()
+}
+
+// SetTopology replaces the topology s serves.
+func (s *Server) SetTopology(topo *Topology) {
+	if topo == nil {
+		topo = &Topology{}
+	}
+
+	ttl := topo.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	zone := buildZone(topo)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.zone = zone
+	s.ttl = uint32(ttl / time.Second)
+}
+
+// SetLatency sets the amount of time s waits before responding to queries.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = d
+}
+
+// Fail instructs s to respond with the given failure to queries for name.
+//
+// Should name be empty, the failure applies to all queries. Passing NoFailure
+// clears a previously injected failure.
+func (s *Server) Fail(name string, f Failure) {
+	name = canonical(name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f == NoFailure {
+		delete(s.failures, name)
+
+		return
+	}
+
+	if s.failures == nil {
+		s.failures = make(map[string]Failure)
+	}
+	s.failures[name] = f
+}
+
+func buildZone(topo *Topology) *zone {
+	z := &zone{
+		records: make(map[string]*records),
+		nearest: make(map[string][]net.IP),
+	}
+	add := func(name string) *records {
+		name = canonical(name)
+
+		r := z.records[name]
+		if r == nil {
+			r = &records{}
+			z.records[name] = r
+		}
+
+		return r
+	}
+
+	apps := make([]string, 0, len(topo.Apps))
+	for _, app := range topo.Apps {
+		apps = append(apps, app.Name)
+
+		var (
+			regions  = map[string]struct{}{}
+			machines []string
+			near     []net.IP
+			far      []net.IP
+		)
+		for _, inst := range app.Instances {
+			regions[inst.Region] = struct{}{}
+
+			if inst.Region == topo.Region {
+				near = append(near, inst.IP)
+			} else {
+				far = append(far, inst.IP)
+			}
+
+			names := []string{
+				app.Name + ".internal",
+				"global." + app.Name + ".internal",
+				inst.Region + "." + app.Name + ".internal",
+			}
+			if inst.ProcessGroup != "" {
+				names = append(names, inst.ProcessGroup+".process."+app.Name+".internal")
+			}
+
+			for _, name := range names {
+				r := add(name)
+				r.ips = append(r.ips, inst.IP)
+			}
+
+			if inst.ID != "" {
+				machines = append(machines, inst.ID+" "+inst.Region)
+			}
+		}
+
+		z.nearest[canonical(app.Name)] = append(near, far...)
+
+		if len(app.Flycast) > 0 {
+			add(app.Name + ".flycast").ips = append([]net.IP(nil), app.Flycast...)
+		}
+
+		add("regions." + app.Name + ".internal").setTXT(sortedKeys(regions))
+		add("vms." + app.Name + ".internal").setTXT(machines)
+	}
+	add("_apps.internal").setTXT(apps)
+
+	peers := make([]string, 0, len(topo.Peers))
+	for _, peer := range topo.Peers {
+		peers = append(peers, peer.Name)
+
+		r := add(peer.Name + "._peer.internal")
+		r.ips = append(r.ips, peer.IP)
+	}
+	add("_peer.internal").setTXT(peers)
+
+	if topo.LocalIP != nil {
+		add("fly-local-6pn").ips = []net.IP{topo.LocalIP}
+	}
+
+	return z
+}
+
+// lookup returns the records of name.
+func (z *zone) lookup(name string) *records {
+	if r := z.records[name]; r != nil {
+		return r
+	}
+
+	// top<n>.nearest.of.<app>.internal
+	const infix = ".nearest.of."
+	if !strings.HasPrefix(name, "top") || !strings.HasSuffix(name, ".internal") {
+		return nil
+	}
+
+	i := strings.Index(name, infix)
+	if i < 0 {
+		return nil
+	}
+
+	n, err := strconv.Atoi(name[3:i])
+	if err != nil || n < 1 {
+		return nil
+	}
+
+	ips, ok := z.nearest[strings.TrimSuffix(name[i+len(infix):], ".internal")]
+	if !ok {
+		return nil
+	} else if n < len(ips) {
+		ips = ips[:n]
+	}
+
+	return &records{ips: ips}
+}
+
+// setTXT sets the TXT record of r to the comma-separated values, as fly does.
+func (r *records) setTXT(values []string) {
+	if len(values) > 0 {
+		r.txt = []string{strings.Join(values, ",")}
+	}
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func canonical(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func (s *Server) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, 0xffff)
+	for {
+		n, addr, err := s.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		query := append([]byte(nil), buf[:n]...)
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+
+			res := s.respond(query, true)
+			if res != nil {
+				_, _ = s.pc.WriteTo(res, addr)
+			}
+		}()
+	}
+}
+
+func (s *Server) serveTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+
+		s.track(conn, true)
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.track(conn, false)
+			defer conn.Close()
+
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (s *Server) track(conn net.Conn, add bool) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	if !add {
+		delete(s.conns, conn)
+
+		return
+	} else if s.closed {
+		_ = conn.Close()
+
+		return
+	}
+
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	for {
+		_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		var l [2]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return
+		}
+
+		query := make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+
+		res := s.respond(query, false)
+		if res == nil {
+			return
+		}
+
+		binary.BigEndian.PutUint16(l[:], uint16(len(res)))
+		if _, err := conn.Write(append(l[:], res...)); err != nil {
+			return
+		}
+	}
+}
+
+// respond returns the wire representation of the response to query, or nil in
+// case no response should be sent.
+func (s *Server) respond(query []byte, udp bool) []byte {
+	q, err := dnswire.Parse(query)
+	if err != nil || q.Response {
+		return nil
+	}
+
+	s.mu.RLock()
+	latency := s.latency
+	s.mu.RUnlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	res := &dnswire.Message{
+		Header: dnswire.Header{
+			ID:                 q.ID,
+			Response:           true,
+			Authoritative:      true,
+			RecursionDesired:   q.RecursionDesired,
+			RecursionAvailable: true,
+		},
+		Questions: q.Questions,
+	}
+
+	if len(q.Questions) != 1 {
+		res.RCode = dnswire.RCodeFormatError
+	} else if !s.answer(res, q.Questions[0]) {
+		return nil
+	}
+
+	data, err := res.Pack()
+	if err != nil {
+		res.Answers = nil
+		res.RCode = dnswire.RCodeServerFailure
+		data, _ = res.Pack()
+	}
+
+	if udp && len(data) > dnswire.MaxUDPSize {
+		res.Answers = nil
+		res.Truncated = true
+		data, _ = res.Pack()
+	}
+
+	return data
+}
+
+// answer populates res with the answer to q. It reports false in case no
+// response should be sent.
+func (s *Server) answer(res *dnswire.Message, q dnswire.Question) bool {
+	name := canonical(q.Name)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.failures[name]
+	if !ok {
+		f = s.failures[""]
+	}
+
+	switch f {
+	case ServerFailure:
+		res.RCode = dnswire.RCodeServerFailure
+		return true
+	case Refused:
+		res.RCode = dnswire.RCodeRefused
+		return true
+	case NameError:
+		res.RCode = dnswire.RCodeNameError
+		return true
+	case Drop:
+		return false
+	}
+
+	r := s.zone.lookup(name)
+	if r == nil {
+		res.RCode = dnswire.RCodeNameError
+
+		return true
+	}
+
+	switch q.Type {
+	case dnswire.TypeTXT:
+		for _, txt := range r.txt {
+			res.Answers = append(res.Answers, dnswire.NewTXT(q.Name, s.ttl, txt))
+		}
+	case dnswire.TypeA, dnswire.TypeAAAA:
+		for _, ip := range r.ips {
+			if rr := dnswire.NewIP(q.Name, s.ttl, ip); rr.Type == q.Type {
+				res.Answers = append(res.Answers, rr)
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,229 @@
+package dnstest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/dns"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+const (
+	ip1  = "fdaa:0:22b7:a7b:abd:aa3c:6498:2"
+	ip2  = "fdaa:0:22b7:a7b:ab8:3071:ecb3:2"
+	ip3  = "fdaa:0:22b7:a7b:aa0:12a5:aacb:2"
+	peer = "fdaa:0:22b7:a8b:ce2:0:a:c02"
+	fc   = "fdaa:0:22b7:0:1::3"
+)
+
+func topology(t *testing.T) *Topology {
+	t.Helper()
+
+	return &Topology{
+		Apps: []App{
+			{
+				Name: "app1",
+				Instances: []Instance{
+					{ID: "1781973f34d089", Region: "iad", IP: testutil.ParseIP(t, ip1)},
+					{ID: "e2865093a38586", Region: "ams", IP: testutil.ParseIP(t, ip2)},
+				},
+				Flycast: []net.IP{testutil.ParseIP(t, fc)},
+			},
+			{
+				Name: "app2",
+				Instances: []Instance{
+					{Region: "ams", IP: testutil.ParseIP(t, ip3)},
+				},
+			},
+		},
+		Peers: []Peer{
+			{Name: "laptop", IP: testutil.ParseIP(t, peer)},
+		},
+		LocalIP: testutil.ParseIP(t, ip1),
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(topology(t))
+	t.Cleanup(s.Close)
+
+	resolvers := map[string]dns.DNS{
+		"direct": dns.NewDirect(s.Addr),
+		"client": dns.New(&dns.Client{Addr: s.Addr}),
+	}
+
+	for name := range resolvers {
+		d := resolvers[name]
+
+		t.Run(name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			apps, err := d.Apps(ctx)
+			testutil.AssertEqual(t, nil, err)
+			testutil.AssertEqual(t, []string{"app1", "app2"}, apps)
+
+			regions, err := d.Regions(ctx, "app1")
+			testutil.AssertEqual(t, nil, err)
+			testutil.AssertEqual(t, []string{"ams", "iad"}, regions)
+
+			all, err := d.Instances(ctx, "app1", "")
+			testutil.AssertEqual(t, nil, err)
+			testutil.AssertEqual(t, []net.IP{
+				testutil.ParseIP(t, ip2),
+				testutil.ParseIP(t, ip1),
+			}, sortIPs(all))
+
+			ams, err := d.Instances(ctx, "app1", "ams")
+			testutil.AssertEqual(t, nil, err)
+			testutil.AssertEqual(t, []net.IP{testutil.ParseIP(t, ip2)}, ams)
+
+			flycast, err := d.(dns.FlycastResolver).Flycast(ctx, "app1")
+			testutil.AssertEqual(t, nil, err)
+			testutil.AssertEqual(t, []net.IP{testutil.ParseIP(t, fc)}, flycast)
+
+			_, err = d.(dns.FlycastResolver).Flycast(ctx, "app2")
+			assertDNSError(t, err, true, false)
+
+			peers, err := d.Peers(ctx)
+			testutil.AssertEqual(t, nil, err)
+			testutil.AssertEqual(t, []string{"laptop"}, peers)
+
+			ip, err := d.Peer(ctx, "laptop")
+			testutil.AssertEqual(t, nil, err)
+			testutil.AssertEqual(t, testutil.ParseIP(t, peer), ip)
+		})
+	}
+}
+
+func TestServerMachinesAndLocalIP(t *testing.T) {
+	s := NewServer(topology(t))
+	t.Cleanup(s.Close)
+
+	c := &dns.Client{Addr: s.Addr}
+
+	txt, err := c.LookupTXTRecords(context.TODO(), "vms.app1.internal")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []dns.TXTRecord{
+		{Text: "1781973f34d089 iad,e2865093a38586 ams", TTL: DefaultTTL},
+	}, txt)
+
+	ips, err := c.LookupIP(context.TODO(), "ip6", "fly-local-6pn")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []net.IP{testutil.ParseIP(t, ip1)}, ips)
+}
+
+func TestServerSetTopology(t *testing.T) {
+	s := NewServer(nil)
+	t.Cleanup(s.Close)
+
+	c := &dns.Client{Addr: s.Addr}
+
+	_, err := c.LookupTXT(context.TODO(), "_apps.internal")
+	assertDNSError(t, err, true, false)
+
+	s.SetTopology(topology(t))
+
+	apps, err := c.LookupTXT(context.TODO(), "_apps.internal")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []string{"app1,app2"}, apps)
+}
+
+func TestServerFailures(t *testing.T) {
+	s := NewServer(topology(t))
+	t.Cleanup(s.Close)
+
+	c := &dns.Client{
+		Addr:    s.Addr,
+		Timeout: 100 * time.Millisecond,
+	}
+
+	cases := []struct {
+		name     string
+		failure  Failure
+		notFound bool
+		timeout  bool
+	}{
+		0: {name: "_apps.internal", failure: ServerFailure},
+		1: {name: "_apps.internal.", failure: Refused},
+		2: {name: "_APPS.internal", failure: NameError, notFound: true},
+		3: {name: "", failure: Drop, timeout: true},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			s.Fail(kase.name, kase.failure)
+			t.Cleanup(func() { s.Fail(kase.name, NoFailure) })
+
+			_, err := c.LookupTXT(context.TODO(), "_apps.internal")
+			assertDNSError(t, err, kase.notFound, kase.timeout)
+		})
+	}
+
+	_, err := c.LookupTXT(context.TODO(), "_apps.internal")
+	testutil.AssertEqual(t, nil, err)
+}
+
+func TestServerLatency(t *testing.T) {
+	s := NewServer(topology(t))
+	t.Cleanup(s.Close)
+
+	const latency = 50 * time.Millisecond
+	s.SetLatency(latency)
+
+	start := time.Now()
+	_, err := (&dns.Client{Addr: s.Addr}).LookupTXT(context.TODO(), "_apps.internal")
+	testutil.AssertEqual(t, nil, err)
+
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("lookup took %s", elapsed)
+	}
+}
+
+func TestServerTruncates(t *testing.T) {
+	topo := &Topology{}
+	for i := 0; i < 40; i++ {
+		topo.Apps = append(topo.Apps, App{
+			Name: "app",
+			Instances: []Instance{
+				{Region: "ams", IP: net.ParseIP("fdaa::" + strconv.Itoa(i+1))},
+			},
+		})
+	}
+
+	s := NewServer(topo)
+	t.Cleanup(s.Close)
+
+	// 40 AAAA records do not fit in a 512-byte datagram; the client has to
+	// retry over TCP
+	ips, err := (&dns.Client{Addr: s.Addr}).LookupIP(context.TODO(), "ip6", "global.app.internal")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, 40, len(ips))
+}
+
+func assertDNSError(t *testing.T, err error, notFound, timeout bool) {
+	t.Helper()
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Fatalf("expected a *net.DNSError, got %v", err)
+	}
+
+	testutil.AssertEqual(t, notFound, dnsErr.IsNotFound)
+	testutil.AssertEqual(t, timeout, dnsErr.IsTimeout)
+}
+
+func sortIPs(ips []net.IP) []net.IP {
+	sort.Slice(ips, func(i, j int) bool {
+		return bytes.Compare(ips[i], ips[j]) == -1
+	})
+
+	return ips
+}
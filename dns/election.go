@@ -0,0 +1,190 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultStabilization denotes the default amount of time the membership of an
+// Election has to remain unchanged for before its leader is (re)determined.
+const DefaultStabilization = 10 * time.Second
+
+// ElectionOptions wraps the options NewElection accepts.
+type ElectionOptions struct {
+	// Region, when set, scopes the election to the instances in the given
+	// region.
+	Region string
+
+	// Interval denotes the interval at which membership is refreshed. Should
+	// Interval be non-positive, DefaultWatchInterval is used.
+	Interval time.Duration
+
+	// Stabilization denotes the amount of time membership has to remain
+	// unchanged for before the leader is (re)determined. Should Stabilization
+	// be zero, DefaultStabilization is used while negative values disable
+	// stabilization altogether.
+	Stabilization time.Duration
+
+	// OnElected, when set, is called when the local instance becomes the
+	// leader.
+	OnElected func()
+
+	// OnDemoted, when set, is called when the local instance stops being the
+	// leader.
+	OnDemoted func()
+}
+
+// Election deterministically elects a leader among the instances of an
+// application (or the ones in a region of it): the instance with the lowest
+// IPv6 address.
+//
+// Elections are safe for concurrent use.
+type Election struct {
+	handle *Handle
+	opts   ElectionOptions
+
+	mu       sync.RWMutex // protects the fields below
+	leader   net.IP
+	isLeader bool
+}
+
+// NewElection returns an Election among the instances of the application h is
+// bound to.
+//
+// A nil opts is treated as the zero value of ElectionOptions.
+func NewElection(h *Handle, opts *ElectionOptions) *Election {
+	e := &Election{
+		handle: h,
+	}
+
+	if opts != nil {
+		e.opts = *opts
+	}
+	if e.opts.Stabilization == 0 {
+		e.opts.Stabilization = DefaultStabilization
+	}
+
+	return e
+}
+
+// IsLeader reports whether the local instance is the leader.
+func (e *Election) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.isLeader
+}
+
+// Leader returns the address of the leader, or nil in case none has been
+// determined yet.
+func (e *Election) Leader() net.IP {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return append(net.IP(nil), e.leader...)
+}
+
+// Run takes part in the election until ctx is done, at which point the local
+// instance steps down (should it be the leader) and Run returns ctx.Err().
+//
+// The leader is determined once membership has remained unchanged for the
+// configured stabilization period. Lookup failures do not affect membership,
+// while failures to determine the address of the local instance are retried
+// at the configured interval.
+func (e *Election) Run(ctx context.Context) error {
+	defer e.update(nil, false)
+
+	var (
+		members []net.IP
+		timer   = time.NewTimer(0)
+		settled <-chan time.Time
+	)
+	defer timer.Stop()
+	<-timer.C
+
+	for ch := e.handle.Watch(ctx, e.opts.Region, e.opts.Interval); ; {
+		select {
+		case m, ok := <-ch:
+			if !ok {
+				return ctx.Err()
+			} else if m.Err != nil {
+				continue
+			}
+
+			members = m.IPs
+
+			stopTimer(timer)
+			timer.Reset(stabilization(e.opts.Stabilization))
+			settled = timer.C
+		case <-settled:
+			leader := lowestIP(members)
+
+			self, err := e.handle.DNS().PrivateIP(ctx)
+			if err != nil {
+				// membership may remain unchanged for long; retry rather than
+				// wait for Watch to report a change
+				timer.Reset(e.interval())
+
+				continue
+			}
+
+			settled = nil
+			e.update(leader, leader != nil && leader.Equal(self))
+		}
+	}
+}
+
+func (e *Election) update(leader net.IP, isLeader bool) {
+	e.mu.Lock()
+	was := e.isLeader
+	e.leader = leader
+	e.isLeader = isLeader
+	e.mu.Unlock()
+
+	switch {
+	case !was && isLeader && e.opts.OnElected != nil:
+		e.opts.OnElected()
+	case was && !isLeader && e.opts.OnDemoted != nil:
+		e.opts.OnDemoted()
+	}
+}
+
+// interval returns the interval at which e refreshes membership.
+func (e *Election) interval() time.Duration {
+	if e.opts.Interval <= 0 {
+		return DefaultWatchInterval
+	}
+
+	return e.opts.Interval
+}
+
+func stabilization(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+// stopTimer stops t and drains its channel, if needed.
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
+
+func lowestIP(ips []net.IP) (lowest net.IP) {
+	for _, ip := range ips {
+		if lowest == nil || bytes.Compare(ip.To16(), lowest.To16()) < 0 {
+			lowest = ip
+		}
+	}
+
+	return
+}
@@ -0,0 +1,178 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/dns/dnstest"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestElection(t *testing.T) {
+	var (
+		ip1 = testutil.ParseIP(t, "fdaa::1")
+		ip2 = testutil.ParseIP(t, "fdaa::2")
+		ip3 = testutil.ParseIP(t, "fdaa::3")
+	)
+
+	topology := func(ips ...net.IP) *dnstest.Topology {
+		app := dnstest.App{Name: "app1"}
+		for _, ip := range ips {
+			app.Instances = append(app.Instances, dnstest.Instance{Region: "iad", IP: ip})
+		}
+
+		return &dnstest.Topology{Apps: []dnstest.App{app}}
+	}
+
+	s := dnstest.NewServer(topology(ip3, ip2, ip1))
+	t.Cleanup(s.Close)
+
+	var (
+		elected, demoted int32
+		elections        = make([]*Election, 0, 3)
+		ctx, cancel      = context.WithCancel(context.TODO())
+		done             = make(chan struct{}, 3)
+	)
+	defer cancel()
+
+	for _, self := range []net.IP{ip1, ip2, ip3} {
+		r := &selfResolver{Resolver: &Client{Addr: s.Addr}, self: self}
+
+		h, err := NewHandle(New(r, WithPrivateIPSources(SourceDNS)), "app1")
+		testutil.AssertEqual(t, nil, err)
+
+		e := NewElection(h, &ElectionOptions{
+			Interval:      5 * time.Millisecond,
+			Stabilization: 20 * time.Millisecond,
+			OnElected:     func() { atomic.AddInt32(&elected, 1) },
+			OnDemoted:     func() { atomic.AddInt32(&demoted, 1) },
+		})
+		elections = append(elections, e)
+
+		go func() {
+			_ = e.Run(ctx)
+			done <- struct{}{}
+		}()
+	}
+
+	eventually(t, elections[0].IsLeader)
+	testutil.AssertEqual(t, false, elections[1].IsLeader())
+	testutil.AssertEqual(t, false, elections[2].IsLeader())
+	for _, e := range elections {
+		eventually(t, func() bool { return e.Leader().Equal(ip1) })
+	}
+
+	// ip1 leaves; ip2 takes over
+	s.SetTopology(topology(ip2, ip3))
+
+	eventually(t, elections[1].IsLeader)
+	eventually(t, func() bool { return !elections[0].IsLeader() })
+	testutil.AssertEqual(t, false, elections[2].IsLeader())
+
+	testutil.AssertEqual(t, int32(2), atomic.LoadInt32(&elected))
+	testutil.AssertEqual(t, int32(1), atomic.LoadInt32(&demoted))
+
+	cancel()
+	for range elections {
+		<-done
+	}
+
+	// the leader steps down once its context is done
+	testutil.AssertEqual(t, false, elections[1].IsLeader())
+	testutil.AssertEqual(t, int32(2), atomic.LoadInt32(&demoted))
+}
+
+func TestElectionStabilizes(t *testing.T) {
+	self := testutil.ParseIP(t, "fdaa::1")
+
+	s := dnstest.NewServer(&dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "app1",
+				Instances: []dnstest.Instance{
+					{Region: "iad", IP: self},
+				},
+			},
+		},
+	})
+	t.Cleanup(s.Close)
+
+	r := &selfResolver{Resolver: &Client{Addr: s.Addr}, self: self}
+	h, err := NewHandle(New(r, WithPrivateIPSources(SourceDNS)), "app1")
+	testutil.AssertEqual(t, nil, err)
+
+	e := NewElection(h, &ElectionOptions{
+		Interval:      time.Millisecond,
+		Stabilization: time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+
+	testutil.AssertEqual(t, context.DeadlineExceeded, e.Run(ctx))
+	testutil.AssertEqual(t, false, e.IsLeader())
+	testutil.AssertEqual(t, net.IP(nil), e.Leader())
+}
+
+func TestElectionRetriesPrivateIP(t *testing.T) {
+	self := testutil.ParseIP(t, "fdaa::1")
+
+	s := dnstest.NewServer(&dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "app1",
+				Instances: []dnstest.Instance{
+					{Region: "iad", IP: self},
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::2")},
+				},
+			},
+		},
+	})
+	t.Cleanup(s.Close)
+
+	r := &selfResolver{Resolver: &Client{Addr: s.Addr}, self: self, failures: 1}
+	h, err := NewHandle(New(r, WithPrivateIPSources(SourceDNS)), "app1")
+	testutil.AssertEqual(t, nil, err)
+
+	e := NewElection(h, &ElectionOptions{
+		Interval:      5 * time.Millisecond,
+		Stabilization: 5 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- e.Run(ctx) }()
+
+	// membership never changes; the failed lookup must still be retried
+	eventually(t, e.IsLeader)
+	testutil.AssertEqual(t, true, atomic.LoadInt32(&r.failures) < 0)
+
+	cancel()
+	testutil.AssertEqual(t, context.Canceled, <-done)
+}
+
+// selfResolver overrides the address fly-local-6pn resolves to, failing the
+// first failures lookups of it.
+type selfResolver struct {
+	Resolver
+
+	self     net.IP
+	failures int32
+}
+
+func (sr *selfResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	if host == "fly-local-6pn" {
+		if atomic.AddInt32(&sr.failures, -1) >= 0 {
+			return nil, &net.DNSError{Err: "server misbehaving", Name: host}
+		}
+
+		return []net.IP{sr.self}, nil
+	}
+
+	return sr.Resolver.LookupIP(ctx, network, host)
+}
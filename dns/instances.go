@@ -0,0 +1,91 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RegionErrors maps regions to the errors that occurred while looking up the
+// instances deployed to them.
+type RegionErrors map[string]error
+
+// Error implements error for RegionErrors.
+func (re RegionErrors) Error() string {
+	regions := sortedKeys(re)
+
+	msgs := make([]string, 0, len(regions))
+	for _, region := range regions {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", region, re[region]))
+	}
+
+	return fmt.Sprintf("dns: failed looking up instances in %d region(s): %s",
+		len(regions), strings.Join(msgs, "; "))
+}
+
+// InstancesByRegion returns the IPv6 addresses of the instances of the named
+// application, grouped by region.
+//
+// The per-region lookups run concurrently (see WithParallelism). Should any of
+// them fail, InstancesByRegion returns the instances of the regions it could
+// look up along with a RegionErrors error.
+func InstancesByRegion(ctx context.Context, appName string) (map[string][]net.IP, error) {
+	return InstancesByRegionOf(ctx, global, appName)
+}
+
+// InstancesByRegionOf is like InstancesByRegion but performs the lookups via d.
+func InstancesByRegionOf(ctx context.Context, d DNS, appName string) (map[string][]net.IP, error) {
+	regions, err := d.Regions(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	regions = unique(regions)
+	sort.Strings(regions)
+
+	var (
+		mu   sync.Mutex
+		ret  = make(map[string][]net.IP, len(regions))
+		errs RegionErrors
+	)
+
+	_ = forEach(ctx, parallelismOf(d), len(regions), func(ctx context.Context, i int) error {
+		ips, err := d.Instances(ctx, appName, regions[i])
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			if errs == nil {
+				errs = make(RegionErrors)
+			}
+			errs[regions[i]] = err
+		} else {
+			ret[regions[i]] = ips
+		}
+
+		return nil // partial failures should not cancel the rest of the lookups
+	})
+
+	// regions which were never looked up due to ctx expiring
+	for _, region := range regions {
+		if _, ok := ret[region]; ok {
+			continue
+		} else if _, ok := errs[region]; ok {
+			continue
+		}
+
+		if errs == nil {
+			errs = make(RegionErrors)
+		}
+		errs[region] = ctx.Err()
+	}
+
+	if errs != nil {
+		return ret, errs
+	}
+
+	return ret, nil
+}
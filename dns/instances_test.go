@@ -0,0 +1,94 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/azazeal/fly/dns/dnstest"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestInstancesByRegion(t *testing.T) {
+	s := dnstest.NewServer(&dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "app1",
+				Instances: []dnstest.Instance{
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::1")},
+					{Region: "ams", IP: testutil.ParseIP(t, "fdaa::2")},
+					{Region: "cdg", IP: testutil.ParseIP(t, "fdaa::3")},
+				},
+			},
+		},
+	})
+	t.Cleanup(s.Close)
+	t.Cleanup(stub(&Client{Addr: s.Addr}))
+
+	got, err := InstancesByRegion(context.TODO(), "app1")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, map[string][]net.IP{
+		"iad": {testutil.ParseIP(t, "fdaa::1")},
+		"ams": {testutil.ParseIP(t, "fdaa::2")},
+		"cdg": {testutil.ParseIP(t, "fdaa::3")},
+	}, got)
+
+	s.Fail("cdg.app1.internal", dnstest.ServerFailure)
+
+	got, err = InstancesByRegion(context.TODO(), "app1")
+	testutil.AssertEqual(t, map[string][]net.IP{
+		"iad": {testutil.ParseIP(t, "fdaa::1")},
+		"ams": {testutil.ParseIP(t, "fdaa::2")},
+	}, got)
+
+	var re RegionErrors
+	if !errors.As(err, &re) {
+		t.Fatalf("expected RegionErrors, got %v", err)
+	}
+	testutil.AssertEqual(t, []string{"cdg"}, sortedKeys(re))
+
+	s.Fail("regions.app1.internal", dnstest.ServerFailure)
+
+	got, err = InstancesByRegion(context.TODO(), "app1")
+	testutil.AssertEqual(t, map[string][]net.IP(nil), got)
+	if err == nil || errors.As(err, &re) {
+		t.Errorf("expected a lookup error, got %v", err)
+	}
+}
+
+func TestInstancesByRegionCanceled(t *testing.T) {
+	d := New(&mockResolver{
+		lookupTXT: func(context.Context, string) ([]string, error) {
+			return []string{"iad,ams"}, nil
+		},
+		lookupIP: func(ctx context.Context, _, _ string) ([]net.IP, error) {
+			return nil, ctx.Err()
+		},
+	}, WithParallelism(1))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	got, err := InstancesByRegionOf(ctx, d, "app1")
+	testutil.AssertEqual(t, map[string][]net.IP{}, got)
+
+	var re RegionErrors
+	if !errors.As(err, &re) {
+		t.Fatalf("expected RegionErrors, got %v", err)
+	}
+	testutil.AssertEqual(t, RegionErrors{
+		"ams": context.Canceled,
+		"iad": context.Canceled,
+	}, re)
+}
+
+func TestRegionErrorsError(t *testing.T) {
+	err := RegionErrors{
+		"iad": errors.New("err1"),
+		"ams": errors.New("err2"),
+	}
+
+	const exp = "dns: failed looking up instances in 2 region(s): ams: err2; iad: err1"
+	testutil.AssertEqual(t, exp, err.Error())
+}
@@ -0,0 +1,61 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"sort"
+)
+
+// PeerInfo wraps the properties of a wireguard peer.
+type PeerInfo struct {
+	// Name denotes the name of the peer.
+	Name string
+
+	// IP denotes the IPv6 address of the peer, if it could be looked up.
+	IP net.IP
+
+	// Err denotes the error that occurred while looking up the address of the
+	// peer, if any.
+	Err error
+}
+
+// PeerList returns the wireguard peers along with their IPv6 addresses, sorted
+// by name.
+//
+// The per-peer lookups run concurrently (see WithParallelism); their failures
+// are reported via the Err field of the respective PeerInfo rather than the
+// returned error.
+func PeerList(ctx context.Context) ([]PeerInfo, error) {
+	return PeerListOf(ctx, global)
+}
+
+// PeerListOf is like PeerList but performs the lookups via d.
+func PeerListOf(ctx context.Context, d DNS) ([]PeerInfo, error) {
+	names, err := d.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names = unique(names)
+	sort.Strings(names)
+
+	peers := make([]PeerInfo, len(names))
+	for i, name := range names {
+		peers[i].Name = name
+	}
+
+	// each lookup writes to its own element; no locking needed
+	_ = forEach(ctx, parallelismOf(d), len(peers), func(ctx context.Context, i int) error {
+		peers[i].IP, peers[i].Err = d.Peer(ctx, peers[i].Name)
+
+		return nil // partial failures should not cancel the rest of the lookups
+	})
+
+	// peers which were never looked up due to ctx expiring
+	for i := range peers {
+		if peers[i].IP == nil && peers[i].Err == nil {
+			peers[i].Err = ctx.Err()
+		}
+	}
+
+	return peers, nil
+}
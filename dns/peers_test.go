@@ -0,0 +1,72 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/azazeal/fly/dns/dnstest"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestPeerList(t *testing.T) {
+	s := dnstest.NewServer(&dnstest.Topology{
+		Peers: []dnstest.Peer{
+			{Name: "peer2", IP: testutil.ParseIP(t, "fdaa::2")},
+			{Name: "peer1", IP: testutil.ParseIP(t, "fdaa::1")},
+			{Name: "peer3", IP: testutil.ParseIP(t, "fdaa::3")},
+		},
+	})
+	t.Cleanup(s.Close)
+	t.Cleanup(stub(&Client{Addr: s.Addr}))
+
+	got, err := PeerList(context.TODO())
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []PeerInfo{
+		{Name: "peer1", IP: testutil.ParseIP(t, "fdaa::1")},
+		{Name: "peer2", IP: testutil.ParseIP(t, "fdaa::2")},
+		{Name: "peer3", IP: testutil.ParseIP(t, "fdaa::3")},
+	}, got)
+
+	s.Fail("peer2._peer.internal", dnstest.ServerFailure)
+
+	got, err = PeerList(context.TODO())
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, 3, len(got))
+	testutil.AssertEqual(t, testutil.ParseIP(t, "fdaa::1"), got[0].IP)
+	testutil.AssertEqual(t, nil, got[0].Err)
+	testutil.AssertEqual(t, "peer2", got[1].Name)
+	testutil.AssertEqual(t, net.IP(nil), got[1].IP)
+	testutil.AssertEqual(t, false, got[1].Err == nil)
+	testutil.AssertEqual(t, testutil.ParseIP(t, "fdaa::3"), got[2].IP)
+
+	s.Fail("_peer.internal", dnstest.ServerFailure)
+
+	got, err = PeerList(context.TODO())
+	testutil.AssertEqual(t, []PeerInfo(nil), got)
+	testutil.AssertEqual(t, false, err == nil)
+}
+
+func TestPeerListCanceled(t *testing.T) {
+	d := New(&mockResolver{
+		lookupTXT: func(context.Context, string) ([]string, error) {
+			return []string{"peer1,peer2"}, nil
+		},
+		lookupIP: func(ctx context.Context, _, _ string) ([]net.IP, error) {
+			return nil, ctx.Err()
+		},
+	}, WithParallelism(1))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	got, err := PeerListOf(ctx, d)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, 2, len(got))
+	for _, p := range got {
+		if !errors.Is(p.Err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", p.Name, p.Err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/azazeal/fly/env"
+)
+
+// PrivateIPSource denotes a source the IPv6 address of the local instance may
+// be determined from.
+type PrivateIPSource int
+
+// The set of sources.
+const (
+	// SourceEnv denotes the FLY_PRIVATE_IP environment variable (see
+	// env.PrivateIPKey).
+	SourceEnv PrivateIPSource = iota + 1
+
+	// SourceDNS denotes the lookup of fly-local-6pn via the Resolver.
+	SourceDNS
+
+	// SourceHosts denotes the fly-local-6pn entry of /etc/hosts.
+	SourceHosts
+)
+
+// String implements fmt.Stringer for PrivateIPSource.
+func (src PrivateIPSource) String() string {
+	switch src {
+	case SourceEnv:
+		return "env"
+	case SourceDNS:
+		return "dns"
+	case SourceHosts:
+		return "hosts"
+	default:
+		return fmt.Sprintf("PrivateIPSource(%d)", int(src))
+	}
+}
+
+// DefaultPrivateIPSources returns the sources, in order, instances of DNS
+// consult by default when determining the IPv6 address of the local instance.
+func DefaultPrivateIPSources() []PrivateIPSource {
+	return []PrivateIPSource{SourceEnv, SourceDNS, SourceHosts}
+}
+
+// WithPrivateIPSources sets the sources, in order, the IPv6 address of the
+// local instance is determined from.
+func WithPrivateIPSources(srcs ...PrivateIPSource) Option {
+	return func(w *wrapper) {
+		w.privateIPSources = append([]PrivateIPSource(nil), srcs...)
+	}
+}
+
+// WithPrivateIPTTL sets the amount of time the IPv6 address of the local
+// instance is cached for. Non-positive values cache the address indefinitely,
+// or until RefreshPrivateIP is called.
+func WithPrivateIPTTL(ttl time.Duration) Option {
+	return func(w *wrapper) {
+		w.privateIPTTL = ttl
+	}
+}
+
+// PrivateIPError maps the sources consulted while determining the IPv6 address
+// of the local instance to the errors they yielded.
+type PrivateIPError map[PrivateIPSource]error
+
+// Error implements error for PrivateIPError.
+func (pe PrivateIPError) Error() string {
+	msgs := make([]string, 0, len(pe))
+	for _, src := range []PrivateIPSource{SourceEnv, SourceDNS, SourceHosts} {
+		if err, ok := pe[src]; ok {
+			msgs = append(msgs, fmt.Sprintf("%s: %v", src, err))
+		}
+	}
+
+	return "dns: failed determining private IP: " + strings.Join(msgs, "; ")
+}
+
+// PrivateIPResolver is implemented by the instances of DNS which, like the ones
+// New returns, determine the IPv6 address of the local instance from one of
+// several sources and cache it.
+type PrivateIPResolver interface {
+	// LookupPrivateIP returns the IPv6 address of the local instance along
+	// with the source it was determined from.
+	//
+	// The sources (see WithPrivateIPSources) are consulted in order until one
+	// of them yields the address, which is then cached (see WithPrivateIPTTL).
+	LookupPrivateIP(ctx context.Context) (net.IP, PrivateIPSource, error)
+
+	// RefreshPrivateIP is like LookupPrivateIP but bypasses the cache.
+	RefreshPrivateIP(ctx context.Context) (net.IP, PrivateIPSource, error)
+}
+
+// LookupPrivateIP returns the IPv6 address of the local instance along with
+// the source it was determined from.
+//
+// Should the instance of DNS the package-level functions use not implement
+// PrivateIPResolver, the address is determined via its PrivateIP method and
+// reported as coming from SourceDNS.
+func LookupPrivateIP(ctx context.Context) (net.IP, PrivateIPSource, error) {
+	if pr, ok := global.(PrivateIPResolver); ok {
+		return pr.LookupPrivateIP(ctx)
+	}
+
+	return privateIPOf(ctx, global)
+}
+
+// RefreshPrivateIP is like LookupPrivateIP but bypasses the cache.
+func RefreshPrivateIP(ctx context.Context) (net.IP, PrivateIPSource, error) {
+	if pr, ok := global.(PrivateIPResolver); ok {
+		return pr.RefreshPrivateIP(ctx)
+	}
+
+	return privateIPOf(ctx, global)
+}
+
+func privateIPOf(ctx context.Context, d DNS) (net.IP, PrivateIPSource, error) {
+	ip, err := d.PrivateIP(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return ip, SourceDNS, nil
+}
+
+// hostsPath denotes the path to the hosts file SourceHosts refers to.
+var hostsPath = "/etc/hosts"
+
+const privateIPHost = "fly-local-6pn"
+
+func (w *wrapper) PrivateIP(ctx context.Context) (ip net.IP, err error) {
+	ip, _, err = w.LookupPrivateIP(ctx)
+
+	return
+}
+
+func (w *wrapper) LookupPrivateIP(ctx context.Context) (net.IP, PrivateIPSource, error) {
+	return w.cachedPrivateIP(ctx, false)
+}
+
+func (w *wrapper) RefreshPrivateIP(ctx context.Context) (net.IP, PrivateIPSource, error) {
+	return w.cachedPrivateIP(ctx, true)
+}
+
+func (w *wrapper) cachedPrivateIP(ctx context.Context, refresh bool) (net.IP, PrivateIPSource, error) {
+	w.privateIPMu.Lock()
+	defer w.privateIPMu.Unlock()
+
+	fresh := w.privateIPTTL <= 0 || time.Since(w.privateIPAt) < w.privateIPTTL
+	if w.privateIP == nil || !fresh || refresh {
+		ip, src, err := w.resolvePrivateIP(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		w.privateIP = ip
+		w.privateIPSource = src
+		w.privateIPAt = time.Now()
+	}
+
+	return append(net.IP(nil), w.privateIP...), w.privateIPSource, nil
+}
+
+func (w *wrapper) resolvePrivateIP(ctx context.Context) (net.IP, PrivateIPSource, error) {
+	errs := make(PrivateIPError, len(w.privateIPSources))
+
+	for _, src := range w.privateIPSources {
+		var (
+			ip  net.IP
+			err error
+		)
+
+		switch src {
+		case SourceEnv:
+			ip, err = privateIPFromEnv()
+		case SourceDNS:
+			ip, err = w.privateIPFromDNS(ctx)
+		case SourceHosts:
+			ip, err = privateIPFromHosts()
+		default:
+			err = errors.New("unknown source")
+		}
+
+		if err == nil {
+			return ip, src, nil
+		}
+		errs[src] = err
+	}
+
+	return nil, 0, errs
+}
+
+func privateIPFromEnv() (net.IP, error) {
+	v, ok := env.LookupPrivateIP()
+	if !ok {
+		return nil, fmt.Errorf("%s not set", env.PrivateIPKey)
+	}
+
+	ip := net.ParseIP(v)
+	if ip == nil {
+		return nil, fmt.Errorf("%s carries invalid address %q", env.PrivateIPKey, v)
+	}
+
+	return ip, nil
+}
+
+func (w *wrapper) privateIPFromDNS(ctx context.Context) (net.IP, error) {
+	ips, err := w.LookupIP(ctx, "ip6", privateIPHost)
+	if err != nil {
+		return nil, err
+	} else if len(ips) == 0 {
+		return nil, fmt.Errorf("no records for %s", privateIPHost)
+	}
+
+	return append(net.IP(nil), ips[0]...), nil
+}
+
+func privateIPFromHosts() (ip net.IP, err error) {
+	f, err := os.Open(hostsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	errFound := errors.New("found")
+	if err = scanHosts(f, func(_ int, addr net.IP, names []string, err error) error {
+		if err != nil {
+			return nil // hosts files may carry entries we can't parse
+		}
+
+		for _, name := range names {
+			if strings.EqualFold(name, privateIPHost) {
+				ip = addr
+
+				return errFound
+			}
+		}
+
+		return nil
+	}); err != nil && !errors.Is(err, errFound) {
+		return nil, err
+	} else if ip == nil {
+		return nil, fmt.Errorf("%s not defined in %s", privateIPHost, hostsPath)
+	}
+
+	return ip, nil
+}
@@ -0,0 +1,174 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/env"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+const (
+	envIP   = "fdaa:0:22b7:a7b:aa0:12a5:aacb:2"
+	dnsIP   = "fdaa:0:22b7:a7b:ab8:244c:ae91:2"
+	hostsIP = "fdaa:0:22b7:a7b:abd:aa3c:6498:2"
+)
+
+func TestLookupPrivateIPSources(t *testing.T) {
+	setHostsPath(t, "fe80::1%lo0 weird-entry\n"+hostsIP+"\tfly-local-6pn\n")
+	t.Setenv(env.PrivateIPKey, envIP)
+
+	r := privateIPResolver(t, nil)
+
+	cases := []struct {
+		srcs   []PrivateIPSource
+		expIP  string
+		expSrc PrivateIPSource
+	}{
+		{srcs: DefaultPrivateIPSources(), expIP: envIP, expSrc: SourceEnv},
+		{srcs: []PrivateIPSource{SourceDNS, SourceEnv}, expIP: dnsIP, expSrc: SourceDNS},
+		{srcs: []PrivateIPSource{SourceHosts, SourceDNS}, expIP: hostsIP, expSrc: SourceHosts},
+	}
+
+	for _, kase := range cases {
+		ip, src, err := New(r, WithPrivateIPSources(kase.srcs...)).(PrivateIPResolver).LookupPrivateIP(context.TODO())
+		testutil.AssertEqual(t, nil, err)
+		testutil.AssertEqual(t, testutil.ParseIP(t, kase.expIP), ip)
+		testutil.AssertEqual(t, kase.expSrc, src)
+	}
+}
+
+func TestLookupPrivateIPFallsBack(t *testing.T) {
+	setHostsPath(t, hostsIP+" fly-local-6pn\n")
+	t.Setenv(env.PrivateIPKey, "not an ip")
+
+	r := privateIPResolver(t, errors.New("dns failure"))
+
+	ip, src, err := New(r).(PrivateIPResolver).LookupPrivateIP(context.TODO())
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, testutil.ParseIP(t, hostsIP), ip)
+	testutil.AssertEqual(t, SourceHosts, src)
+}
+
+func TestLookupPrivateIPFails(t *testing.T) {
+	setHostsPath(t, "127.0.0.1 localhost\n")
+	t.Setenv(env.PrivateIPKey, "")
+	if err := os.Unsetenv(env.PrivateIPKey); err != nil {
+		t.Fatalf("failed unsetting %s: %v", env.PrivateIPKey, err)
+	}
+
+	r := privateIPResolver(t, errors.New("dns failure"))
+
+	_, _, err := New(r).(PrivateIPResolver).LookupPrivateIP(context.TODO())
+
+	var pe PrivateIPError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected a PrivateIPError, got %v", err)
+	}
+	testutil.AssertEqual(t, 3, len(pe))
+
+	msg := err.Error()
+	for _, exp := range []string{"env: FLY_PRIVATE_IP not set", "dns: dns failure", "hosts: fly-local-6pn not defined"} {
+		if !strings.Contains(msg, exp) {
+			t.Errorf("expected %q to contain %q", msg, exp)
+		}
+	}
+}
+
+func TestPrivateIPCaching(t *testing.T) {
+	var calls int32
+	r := &mockResolver{
+		lookupIP: func(context.Context, string, string) ([]net.IP, error) {
+			atomic.AddInt32(&calls, 1)
+
+			return []net.IP{testutil.ParseIP(t, dnsIP)}, nil
+		},
+	}
+
+	d := New(r, WithPrivateIPSources(SourceDNS))
+	for i := 0; i < 3; i++ {
+		_, err := d.PrivateIP(context.TODO())
+		testutil.AssertEqual(t, nil, err)
+	}
+	testutil.AssertEqual(t, int32(1), atomic.LoadInt32(&calls))
+
+	ip, src, err := d.(PrivateIPResolver).RefreshPrivateIP(context.TODO())
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, testutil.ParseIP(t, dnsIP), ip)
+	testutil.AssertEqual(t, SourceDNS, src)
+	testutil.AssertEqual(t, int32(2), atomic.LoadInt32(&calls))
+
+	const ttl = 10 * time.Millisecond
+	d = New(r, WithPrivateIPSources(SourceDNS), WithPrivateIPTTL(ttl))
+	_, _ = d.PrivateIP(context.TODO())
+	_, _ = d.PrivateIP(context.TODO())
+	testutil.AssertEqual(t, int32(3), atomic.LoadInt32(&calls))
+
+	time.Sleep(2 * ttl)
+	_, _ = d.PrivateIP(context.TODO())
+	testutil.AssertEqual(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestLookupPrivateIPWithoutResolver(t *testing.T) {
+	// embedding hides the methods wrapper implements beyond DNS
+	t.Cleanup(stub(nil))
+	SetDefault(struct{ DNS }{New(privateIPResolver(t, nil), WithPrivateIPSources(SourceDNS))})
+
+	_, ok := Default().(PrivateIPResolver)
+	testutil.AssertEqual(t, false, ok)
+
+	ip, src, err := LookupPrivateIP(context.TODO())
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, testutil.ParseIP(t, dnsIP), ip)
+	testutil.AssertEqual(t, SourceDNS, src)
+
+	ip, src, err = RefreshPrivateIP(context.TODO())
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, testutil.ParseIP(t, dnsIP), ip)
+	testutil.AssertEqual(t, SourceDNS, src)
+}
+
+func TestPrivateIPSourceString(t *testing.T) {
+	testutil.AssertEqual(t, "env", SourceEnv.String())
+	testutil.AssertEqual(t, "dns", SourceDNS.String())
+	testutil.AssertEqual(t, "hosts", SourceHosts.String())
+	testutil.AssertEqual(t, "PrivateIPSource(42)", PrivateIPSource(42).String())
+}
+
+func privateIPResolver(t *testing.T, err error) Resolver {
+	t.Helper()
+
+	ip := testutil.ParseIP(t, dnsIP)
+
+	return &mockResolver{
+		lookupIP: func(_ context.Context, _, host string) ([]net.IP, error) {
+			if err != nil {
+				return nil, err
+			} else if host != "fly-local-6pn" {
+				return nil, staticNotFound(host)
+			}
+
+			return []net.IP{ip}, nil
+		},
+	}
+}
+
+func setHostsPath(t *testing.T, contents string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed writing hosts: %v", err)
+	}
+
+	old := hostsPath
+	hostsPath = path
+	t.Cleanup(func() { hostsPath = old })
+}
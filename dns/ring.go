@@ -0,0 +1,199 @@
+package dns
+
+import (
+	"context"
+	"hash/fnv"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RingOptions wraps the options NewRing accepts.
+type RingOptions struct {
+	// Region, when set, limits the members of the ring to the instances in the
+	// given region. Set it to env.Region() for a region-local ring.
+	Region string
+
+	// Replicas denotes the number of owners Owners returns for each key.
+	// Should Replicas be less than 1, 1 is used.
+	Replicas int
+
+	// Interval denotes the interval at which membership is refreshed. Should
+	// Interval be non-positive, DefaultWatchInterval is used.
+	Interval time.Duration
+}
+
+// Ring maps keys to the instances of an application via rendezvous (highest
+// random weight) hashing, so that membership changes only move the keys of the
+// instances which joined or left.
+//
+// Rings are safe for concurrent use.
+type Ring struct {
+	handle   *Handle
+	region   string
+	replicas int
+	interval time.Duration
+
+	readyOnce sync.Once
+	ready     chan struct{}
+
+	mu      sync.RWMutex // protects the fields below
+	members []net.IP
+	err     error
+}
+
+// NewRing returns a Ring over the instances of the application h is bound to.
+//
+// The Ring has no members until either Run fetches them or Set is called.
+//
+// A nil opts is treated as the zero value of RingOptions.
+func NewRing(h *Handle, opts *RingOptions) *Ring {
+	if opts == nil {
+		opts = &RingOptions{}
+	}
+
+	r := &Ring{
+		handle:   h,
+		region:   opts.Region,
+		replicas: opts.Replicas,
+		interval: opts.Interval,
+		ready:    make(chan struct{}),
+	}
+	if r.replicas < 1 {
+		r.replicas = 1
+	}
+
+	return r
+}
+
+// Run keeps the membership of r up to date until ctx is done, at which point it
+// returns ctx.Err().
+//
+// Lookup failures do not affect the membership of r; they're reported by Err
+// instead.
+func (r *Ring) Run(ctx context.Context) error {
+	for m := range r.handle.Watch(ctx, r.region, r.interval) {
+		if m.Err != nil {
+			r.mu.Lock()
+			r.err = m.Err
+			r.mu.Unlock()
+
+			continue
+		}
+
+		r.Set(m.IPs)
+	}
+
+	return ctx.Err()
+}
+
+// Set replaces the members of r.
+func (r *Ring) Set(members []net.IP) {
+	cp := make([]net.IP, 0, len(members))
+	for _, ip := range members {
+		cp = append(cp, append(net.IP(nil), ip.To16()...))
+	}
+	sortIPs(cp)
+
+	r.mu.Lock()
+	r.members = cp
+	r.err = nil
+	r.mu.Unlock()
+
+	r.readyOnce.Do(func() { close(r.ready) })
+}
+
+// Ready returns a channel which is closed once r receives its first membership.
+func (r *Ring) Ready() <-chan struct{} {
+	return r.ready
+}
+
+// Err returns the error the last membership refresh yielded, if any.
+func (r *Ring) Err() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.err
+}
+
+// Members returns the sorted members of r.
+func (r *Ring) Members() []net.IP {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	members := make([]net.IP, 0, len(r.members))
+	for _, ip := range r.members {
+		members = append(members, append(net.IP(nil), ip...))
+	}
+
+	return members
+}
+
+// Owner returns the member which owns key or nil, in case r has no members.
+func (r *Ring) Owner(key string) net.IP {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		owner net.IP
+		best  uint64
+	)
+	for _, ip := range r.members {
+		if score := rendezvous(key, ip); owner == nil || score > best {
+			owner, best = ip, score
+		}
+	}
+
+	return append(net.IP(nil), owner...)
+}
+
+// Owners returns, in order of preference, the members which own key. The
+// number of owners is bounded by the replication factor of r.
+func (r *Ring) Owners(key string) []net.IP {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type scored struct {
+		ip    net.IP
+		score uint64
+	}
+
+	all := make([]scored, 0, len(r.members))
+	for _, ip := range r.members {
+		all = append(all, scored{ip, rendezvous(key, ip)})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].score > all[j].score
+	})
+
+	n := r.replicas
+	if n > len(all) {
+		n = len(all)
+	}
+
+	owners := make([]net.IP, 0, n)
+	for _, s := range all[:n] {
+		owners = append(owners, append(net.IP(nil), s.ip...))
+	}
+
+	return owners
+}
+
+// rendezvous returns the weight of key for the given member.
+func rendezvous(key string, member net.IP) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(member.To16())
+
+	// FNV alone distributes similar inputs poorly; finalize via splitmix64
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
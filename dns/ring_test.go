@@ -0,0 +1,161 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/dns/dnstest"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestRingOwner(t *testing.T) {
+	r := NewRing(nil, nil)
+	testutil.AssertEqual(t, net.IP(nil), r.Owner("key"))
+	testutil.AssertEqual(t, []net.IP{}, r.Owners("key"))
+
+	members := ringMembers(t, 5)
+	r.Set(members)
+
+	counts := map[string]int{}
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+
+		owner := r.Owner(key)
+		testutil.AssertEqual(t, owner, r.Owner(key))
+		testutil.AssertEqual(t, []net.IP{owner}, r.Owners(key))
+
+		counts[owner.String()]++
+	}
+
+	testutil.AssertEqual(t, len(members), len(counts))
+	for ip, n := range counts {
+		if n < 700 || n > 1300 {
+			t.Errorf("%s owns %d of 5000 keys", ip, n)
+		}
+	}
+}
+
+func TestRingMinimalMovement(t *testing.T) {
+	members := ringMembers(t, 5)
+
+	before := NewRing(nil, nil)
+	before.Set(members)
+
+	after := NewRing(nil, nil)
+	after.Set(members[:4]) // the last member left
+
+	left := members[4]
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+
+		if was := before.Owner(key); !was.Equal(left) {
+			testutil.AssertEqual(t, was, after.Owner(key))
+		}
+	}
+}
+
+func TestRingOwners(t *testing.T) {
+	members := ringMembers(t, 4)
+
+	r := NewRing(nil, &RingOptions{Replicas: 3})
+	r.Set(members)
+
+	owners := r.Owners("key")
+	testutil.AssertEqual(t, 3, len(owners))
+	testutil.AssertEqual(t, r.Owner("key"), owners[0])
+
+	seen := map[string]bool{}
+	for _, ip := range owners {
+		seen[ip.String()] = true
+	}
+	testutil.AssertEqual(t, 3, len(seen))
+
+	// replicas are bounded by the number of members
+	r.Set(members[:2])
+	testutil.AssertEqual(t, 2, len(r.Owners("key")))
+}
+
+func TestRingReturnsCopies(t *testing.T) {
+	members := ringMembers(t, 3)
+
+	r := NewRing(nil, &RingOptions{Replicas: 3})
+	r.Set(members)
+
+	owner := r.Owner("key")
+	for _, ips := range [][]net.IP{r.Members(), r.Owners("key"), {r.Owner("key")}} {
+		for _, ip := range ips {
+			ip[len(ip)-1] ^= 0xff
+		}
+	}
+
+	testutil.AssertEqual(t, members, r.Members())
+	testutil.AssertEqual(t, owner, r.Owner("key"))
+}
+
+func TestRingRun(t *testing.T) {
+	members := ringMembers(t, 3)
+
+	topology := func(ips ...net.IP) *dnstest.Topology {
+		app := dnstest.App{Name: "app1"}
+		for _, ip := range ips {
+			app.Instances = append(app.Instances, dnstest.Instance{Region: "iad", IP: ip})
+		}
+		app.Instances = append(app.Instances, dnstest.Instance{Region: "ams", IP: testutil.ParseIP(t, "fdaa::ff")})
+
+		return &dnstest.Topology{Apps: []dnstest.App{app}}
+	}
+
+	s := dnstest.NewServer(topology(members...))
+	t.Cleanup(s.Close)
+
+	h, err := NewHandle(New(&Client{Addr: s.Addr}), "app1")
+	testutil.AssertEqual(t, nil, err)
+
+	r := NewRing(h, &RingOptions{Region: "iad", Interval: 5 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	select {
+	case <-r.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the ring")
+	}
+	testutil.AssertEqual(t, members, r.Members())
+
+	s.Fail("", dnstest.ServerFailure)
+	eventually(t, func() bool { return r.Err() != nil })
+	testutil.AssertEqual(t, members, r.Members()) // failures keep the membership
+
+	s.Fail("", dnstest.NoFailure)
+	s.SetTopology(topology(members[:2]...))
+	eventually(t, func() bool { return len(r.Members()) == 2 })
+	testutil.AssertEqual(t, nil, r.Err())
+
+	cancel()
+	testutil.AssertEqual(t, context.Canceled, <-done)
+}
+
+func ringMembers(t *testing.T, n int) (members []net.IP) {
+	t.Helper()
+
+	for i := 1; i <= n; i++ {
+		members = append(members, testutil.ParseIP(t, fmt.Sprintf("fdaa:0:22b7:a7b:abd:aa3c:6498:%x", i)))
+	}
+
+	return
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/azazeal/fly/env"
+)
+
+// ErrNoAppName is returned by the functions which default to the name of the
+// current application, when it isn't defined (see env.AppName).
+var ErrNoAppName = errors.New("dns: application name not defined")
+
+// SiblingsOptions wraps the options Siblings accepts.
+type SiblingsOptions struct {
+	// App denotes the name of the application. Should App be empty, the name
+	// of the current application is used (see env.AppName).
+	App string
+
+	// Region, when set, limits the siblings to the ones in the given region.
+	Region string
+
+	// ProcessGroup, when set, limits the siblings to the ones in the given
+	// process group.
+	ProcessGroup string
+
+	// Port denotes the port each of the returned seeds carries.
+	//
+	// Should Port be zero, the seeds are bare IPv6 addresses (e.g. fdaa::2)
+	// rather than host:port pairs.
+	Port int
+}
+
+// Siblings returns the addresses of the instances of an application,
+// excluding the local one, as host:port seeds (e.g. for gossip or raft
+// bootstrapping).
+//
+// A nil opts is treated as the zero value of SiblingsOptions; the seeds are
+// then bare IPv6 addresses (see SiblingsOptions.Port).
+func Siblings(ctx context.Context, opts *SiblingsOptions) ([]string, error) {
+	return SiblingsOf(ctx, global, opts)
+}
+
+// SiblingsOf is like Siblings but performs the lookups via d.
+func SiblingsOf(ctx context.Context, d DNS, opts *SiblingsOptions) ([]string, error) {
+	if opts == nil {
+		opts = &SiblingsOptions{}
+	}
+
+	if opts.Port < 0 || opts.Port > 65535 {
+		return nil, fmt.Errorf("dns: invalid port: %d", opts.Port)
+	}
+
+	app := opts.App
+	if app == "" {
+		if app = env.AppName(); app == "" {
+			return nil, ErrNoAppName
+		}
+	}
+
+	var (
+		ips []net.IP
+		err error
+	)
+	if opts.ProcessGroup == "" {
+		ips, err = d.Instances(ctx, app, opts.Region)
+	} else {
+		ips, err = processInstancesOf(ctx, d, app, opts.ProcessGroup)
+
+		if err == nil && opts.Region != "" {
+			var regional []net.IP
+			if regional, err = d.Instances(ctx, app, opts.Region); err == nil {
+				ips = intersectIPs(ips, regional)
+			}
+		}
+	}
+	if err = ignoreNotFound(err); err != nil {
+		return nil, err
+	}
+
+	self, err := d.PrivateIP(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sortIPs(ips)
+
+	seeds := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if ip.Equal(self) {
+			continue
+		}
+
+		if opts.Port == 0 {
+			seeds = append(seeds, ip.String())
+		} else {
+			seeds = append(seeds, net.JoinHostPort(ip.String(), strconv.Itoa(opts.Port)))
+		}
+	}
+
+	return seeds, nil
+}
+
+func intersectIPs(a, b []net.IP) (ret []net.IP) {
+	for _, ip := range a {
+		if containsIP(b, ip) {
+			ret = append(ret, ip)
+		}
+	}
+
+	return
+}
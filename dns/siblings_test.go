@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/azazeal/fly/dns/dnstest"
+	"github.com/azazeal/fly/env"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestSiblings(t *testing.T) {
+	s := dnstest.NewServer(&dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "app1",
+				Instances: []dnstest.Instance{
+					{Region: "iad", ProcessGroup: "web", IP: testutil.ParseIP(t, "fdaa::1")},
+					{Region: "iad", ProcessGroup: "web", IP: testutil.ParseIP(t, "fdaa::2")},
+					{Region: "iad", ProcessGroup: "worker", IP: testutil.ParseIP(t, "fdaa::3")},
+					{Region: "ams", ProcessGroup: "web", IP: testutil.ParseIP(t, "fdaa::4")},
+				},
+			},
+		},
+		LocalIP: testutil.ParseIP(t, "fdaa::1"),
+	})
+	t.Cleanup(s.Close)
+	t.Cleanup(stub(&Client{Addr: s.Addr}))
+
+	t.Setenv(env.AppNameKey, "app1")
+
+	cases := []struct {
+		opts *SiblingsOptions
+		exp  []string
+	}{
+		0: {
+			exp: []string{"fdaa::2", "fdaa::3", "fdaa::4"},
+		},
+		1: {
+			opts: &SiblingsOptions{Port: 7946},
+			exp:  []string{"[fdaa::2]:7946", "[fdaa::3]:7946", "[fdaa::4]:7946"},
+		},
+		2: {
+			opts: &SiblingsOptions{Region: "iad"},
+			exp:  []string{"fdaa::2", "fdaa::3"},
+		},
+		3: {
+			opts: &SiblingsOptions{ProcessGroup: "web"},
+			exp:  []string{"fdaa::2", "fdaa::4"},
+		},
+		4: {
+			opts: &SiblingsOptions{ProcessGroup: "web", Region: "ams", Port: 1},
+			exp:  []string{"[fdaa::4]:1"},
+		},
+		5: {
+			opts: &SiblingsOptions{Region: "syd"},
+			exp:  []string{},
+		},
+		6: {
+			opts: &SiblingsOptions{App: "app2"},
+			exp:  []string{},
+		},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			got, err := Siblings(context.TODO(), kase.opts)
+			testutil.AssertEqual(t, nil, err)
+			testutil.AssertEqual(t, kase.exp, got)
+		})
+	}
+}
+
+func TestSiblingsWithoutAppName(t *testing.T) {
+	t.Setenv(env.AppNameKey, "")
+	if err := os.Unsetenv(env.AppNameKey); err != nil {
+		t.Fatalf("failed unsetting %s: %v", env.AppNameKey, err)
+	}
+
+	_, err := Siblings(context.TODO(), nil)
+	testutil.AssertEqual(t, true, errors.Is(err, ErrNoAppName))
+}
+
+func TestSiblingsInvalidPort(t *testing.T) {
+	for _, port := range []int{-1, 65536} {
+		_, err := SiblingsOf(context.TODO(), New(nil), &SiblingsOptions{App: "app1", Port: port})
+		if err == nil || !strings.Contains(err.Error(), "invalid port") {
+			t.Errorf("unexpected error for port %d: %v", port, err)
+		}
+	}
+}
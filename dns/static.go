@@ -0,0 +1,274 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/azazeal/fly/env"
+)
+
+// Static implements a Resolver backed by a static set of records, for use when
+// running off fly.
+//
+// Names are matched case-insensitively and regardless of a trailing dot.
+//
+// Since off-fly environments (e.g. docker-compose networks) rarely carry IPv6
+// addresses, lookups for the "ip6" network return IPv4 addresses as well.
+type Static struct {
+	// IPs maps names to the addresses they resolve to.
+	IPs map[string][]net.IP `json:"ip,omitempty"`
+
+	// TXT maps names to the TXT records they resolve to.
+	TXT map[string][]string `json:"txt,omitempty"`
+}
+
+var _ Resolver = (*Static)(nil)
+
+// LookupTXT implements Resolver for Static.
+func (s *Static) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if txts := s.TXT[canonicalName(name)]; len(txts) > 0 {
+		return append([]string(nil), txts...), nil
+	}
+
+	return nil, staticNotFound(name)
+}
+
+// LookupIP implements Resolver for Static.
+func (s *Static) LookupIP(_ context.Context, network, host string) (ips []net.IP, err error) {
+	switch network {
+	case "ip", "ip6":
+		ips = append(ips, s.IPs[canonicalName(host)]...)
+	case "ip4":
+		for _, ip := range s.IPs[canonicalName(host)] {
+			if ip.To4() != nil {
+				ips = append(ips, ip)
+			}
+		}
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	if len(ips) == 0 {
+		err = staticNotFound(host)
+	}
+
+	return
+}
+
+// LoadStatic loads the Static the file at path describes.
+//
+// Files with a .json extension are parsed via ParseStaticJSON while all other
+// files are parsed via ParseHosts.
+func LoadStatic(path string) (*Static, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ParseStaticJSON(f)
+	}
+
+	return ParseHosts(f)
+}
+
+// ParseStaticJSON parses the JSON representation of a Static, as read from r.
+//
+// An example of the format follows:
+//
+//	{
+//	  "ip": {
+//	    "iad.db.internal": ["172.18.0.2"],
+//	    "global.db.internal": ["172.18.0.2"]
+//	  },
+//	  "txt": {
+//	    "_apps.internal": ["db"],
+//	    "regions.db.internal": ["iad"]
+//	  }
+//	}
+func ParseStaticJSON(r io.Reader) (*Static, error) {
+	var raw Static
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("dns: failed decoding static records: %w", err)
+	}
+
+	s := &Static{}
+	for name, ips := range raw.IPs {
+		s.addIP(name, ips...)
+	}
+	for name, txts := range raw.TXT {
+		s.addTXT(name, txts...)
+	}
+
+	return s, nil
+}
+
+// ParseHosts parses the hosts(5)-formatted records r carries.
+//
+// Since hosts files may not carry TXT records, ParseHosts derives them, along
+// with aggregate addresses, from names of the <region>.<app>.internal form:
+// _apps.internal, regions.<app>.internal, global.<app>.internal and
+// <app>.internal are populated accordingly, unless they're explicitly defined.
+func ParseHosts(r io.Reader) (*Static, error) {
+	s := &Static{}
+
+	if err := scanHosts(r, func(line int, ip net.IP, names []string, err error) error {
+		if err != nil {
+			return fmt.Errorf("dns: hosts line %d: %w", line, err)
+		}
+
+		for _, name := range names {
+			s.addIP(name, ip)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	s.derive()
+
+	return s, nil
+}
+
+// scanHosts calls fn for each non-empty line of the hosts(5)-formatted r, with
+// either the line's address and names or the error parsing the line yielded.
+// It stops at the first error fn returns.
+func scanHosts(r io.Reader, fn func(line int, ip net.IP, names []string, err error) error) error {
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+
+		var (
+			ip     net.IP
+			err    error
+			fields = strings.Fields(text)
+		)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) < 2:
+			err = errors.New("missing hostnames")
+		default:
+			if ip = net.ParseIP(fields[0]); ip == nil {
+				err = fmt.Errorf("invalid address %q", fields[0])
+			}
+		}
+
+		if err = fn(line, ip, fields[1:], err); err != nil {
+			return err
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("dns: failed reading hosts: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Static) derive() {
+	var (
+		explicit = make(map[string]bool, len(s.IPs))
+		apps     = map[string]map[string]struct{}{}
+		ips      = map[string][]net.IP{}
+	)
+	for name := range s.IPs {
+		explicit[name] = true
+	}
+
+	for name, addrs := range s.IPs {
+		labels := strings.Split(name, ".")
+		if len(labels) != 3 || labels[2] != "internal" || labels[0] == "global" ||
+			strings.HasPrefix(labels[1], "_") || strings.HasPrefix(labels[0], "_") {
+			continue
+		}
+
+		region, app := labels[0], labels[1]
+		if apps[app] == nil {
+			apps[app] = map[string]struct{}{}
+		}
+		apps[app][region] = struct{}{}
+		ips[app] = append(ips[app], addrs...)
+	}
+
+	names := make([]string, 0, len(apps))
+	for app, regions := range apps {
+		names = append(names, app)
+
+		if _, ok := s.TXT["regions."+app+".internal"]; !ok {
+			s.addTXT("regions."+app+".internal", strings.Join(sortedKeys(regions), ","))
+		}
+
+		for _, name := range []string{"global." + app + ".internal", app + ".internal"} {
+			if !explicit[name] {
+				s.addIP(name, ips[app]...)
+			}
+		}
+	}
+
+	if _, ok := s.TXT["_apps.internal"]; !ok && len(names) > 0 {
+		sort.Strings(names)
+		s.addTXT("_apps.internal", strings.Join(names, ","))
+	}
+}
+
+func (s *Static) addIP(name string, ips ...net.IP) {
+	if s.IPs == nil {
+		s.IPs = make(map[string][]net.IP)
+	}
+
+	name = canonicalName(name)
+	s.IPs[name] = append(s.IPs[name], ips...)
+}
+
+func (s *Static) addTXT(name string, txts ...string) {
+	if s.TXT == nil {
+		s.TXT = make(map[string][]string)
+	}
+
+	name = canonicalName(name)
+	s.TXT[name] = append(s.TXT[name], txts...)
+}
+
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func staticNotFound(name string) error {
+	return &net.DNSError{
+		Err:        "no such host",
+		Name:       name,
+		Server:     "static",
+		IsNotFound: true,
+	}
+}
+
+// NewAuto returns an instance of DNS that uses net.DefaultResolver when
+// running on fly (see env.IsSet) or the Static the file at path describes (see
+// LoadStatic) otherwise.
+func NewAuto(path string) (DNS, error) {
+	if env.IsSet() {
+		return New(net.DefaultResolver), nil
+	}
+
+	s, err := LoadStatic(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(s), nil
+}
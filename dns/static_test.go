@@ -0,0 +1,130 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/azazeal/fly/env"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+const hosts = `# docker-compose services
+172.18.0.2	iad.db.internal
+172.18.0.3	ams.db.internal   # replica
+172.18.0.4	iad.web.internal web.local
+::1	fly-local-6pn
+`
+
+func TestParseHosts(t *testing.T) {
+	s, err := ParseHosts(strings.NewReader(hosts))
+	testutil.AssertEqual(t, nil, err)
+
+	d := New(s, WithPrivateIPSources(SourceDNS))
+	ctx := context.TODO()
+
+	apps, err := d.Apps(ctx)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []string{"db", "web"}, apps)
+
+	regions, err := d.Regions(ctx, "db")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []string{"ams", "iad"}, regions)
+
+	all, err := d.Instances(ctx, "db", "")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, 2, len(all))
+
+	iad, err := d.Instances(ctx, "DB", "iad")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []net.IP{testutil.ParseIP(t, "172.18.0.2")}, iad)
+
+	ip, err := d.PrivateIP(ctx)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, testutil.ParseIP(t, "::1"), ip)
+
+	web, err := s.LookupIP(ctx, "ip4", "web.local.")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []net.IP{testutil.ParseIP(t, "172.18.0.4")}, web)
+
+	_, err = s.LookupIP(ctx, "ip4", "fly-local-6pn")
+	assertNotFound(t, err)
+
+	_, err = d.Peers(ctx)
+	assertNotFound(t, err)
+}
+
+func TestParseHostsInvalid(t *testing.T) {
+	for _, in := range []string{
+		"172.18.0.2\n",
+		"not-an-ip iad.db.internal\n",
+	} {
+		_, err := ParseHosts(strings.NewReader(in))
+		if err == nil {
+			t.Errorf("expected an error parsing %q", in)
+		}
+	}
+}
+
+func TestParseStaticJSON(t *testing.T) {
+	const doc = `{
+		"ip": {"iad.db.internal.": ["fdaa::2"]},
+		"txt": {"_apps.internal": ["db,web", "api"]}
+	}`
+
+	s, err := ParseStaticJSON(strings.NewReader(doc))
+	testutil.AssertEqual(t, nil, err)
+
+	apps, err := New(s).Apps(context.TODO())
+	testutil.AssertEqual(t, nil, err)
+	sort.Strings(apps)
+	testutil.AssertEqual(t, []string{"api", "db", "web"}, apps)
+
+	ips, err := New(s).Instances(context.TODO(), "db", "iad")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []net.IP{testutil.ParseIP(t, "fdaa::2")}, ips)
+
+	// no derivation takes place for JSON
+	_, err = New(s).Regions(context.TODO(), "db")
+	assertNotFound(t, err)
+}
+
+func TestNewAuto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte(hosts), 0o600); err != nil {
+		t.Fatalf("failed writing hosts: %v", err)
+	}
+
+	t.Run("off fly", func(t *testing.T) {
+		testutil.UnsetEnv(t, "FLY_")
+
+		d, err := NewAuto(path)
+		testutil.AssertEqual(t, nil, err)
+
+		_, ok := d.(*wrapper).Resolver.(*Static)
+		testutil.AssertEqual(t, true, ok)
+	})
+
+	t.Run("on fly", func(t *testing.T) {
+		for _, key := range []string{env.AppNameKey, env.AllocIDKey, env.PublicIPKey, env.RegionKey} {
+			t.Setenv(key, token(t))
+		}
+
+		d, err := NewAuto(filepath.Join(t.TempDir(), "missing"))
+		testutil.AssertEqual(t, nil, err)
+		testutil.AssertEqual(t, Resolver(net.DefaultResolver), d.(*wrapper).Resolver)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		testutil.UnsetEnv(t, "FLY_")
+
+		_, err := NewAuto(filepath.Join(t.TempDir(), "missing.json"))
+		if !os.IsNotExist(err) {
+			t.Errorf("expected a not exist error, got %v", err)
+		}
+	})
+}
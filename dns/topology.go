@@ -0,0 +1,274 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Topology wraps the layout of an organization at a point in time.
+type Topology struct {
+	// CapturedAt denotes the time at which the capture of the Topology began.
+	CapturedAt time.Time `json:"captured_at"`
+
+	// Apps maps the names of the organization's applications to their
+	// instances, grouped by region.
+	Apps map[string]map[string][]net.IP `json:"apps"`
+}
+
+// Snapshot returns the Topology of the current organization.
+//
+// The lookups run concurrently (see WithParallelism).
+func Snapshot(ctx context.Context) (*Topology, error) {
+	return SnapshotOf(ctx, global)
+}
+
+// SnapshotOf is like Snapshot but resolves the Topology via d.
+func SnapshotOf(ctx context.Context, d DNS) (*Topology, error) {
+	parallelism := parallelismOf(d)
+
+	topo := &Topology{
+		CapturedAt: time.Now().UTC(),
+	}
+
+	apps, err := d.Apps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	apps = unique(apps)
+
+	topo.Apps = make(map[string]map[string][]net.IP, len(apps))
+	regions := make([][]string, len(apps))
+
+	if err := forEach(ctx, parallelism, len(apps), func(ctx context.Context, i int) (err error) {
+		regions[i], err = d.Regions(ctx, apps[i])
+		regions[i] = unique(regions[i])
+
+		return ignoreNotFound(err)
+	}); err != nil {
+		return nil, err
+	}
+
+	type target struct{ app, region string }
+
+	var targets []target
+	for i, app := range apps {
+		topo.Apps[app] = make(map[string][]net.IP, len(regions[i]))
+
+		for _, region := range regions[i] {
+			targets = append(targets, target{app, region})
+		}
+	}
+
+	var mu sync.Mutex
+	if err := forEach(ctx, parallelism, len(targets), func(ctx context.Context, i int) error {
+		ips, err := d.Instances(ctx, targets[i].app, targets[i].region)
+		if err = ignoreNotFound(err); err != nil {
+			return err
+		}
+		sortIPs(ips)
+
+		mu.Lock()
+		topo.Apps[targets[i].app][targets[i].region] = ips
+		mu.Unlock()
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return topo, nil
+}
+
+// parallelismOf returns the parallelism d was configured with, should New have
+// returned it, or DefaultParallelism otherwise.
+func parallelismOf(d DNS) int {
+	if w, ok := d.(*wrapper); ok {
+		return w.parallelism
+	}
+
+	return DefaultParallelism
+}
+
+// forEach calls fn for each index in [0, n), with up to parallelism calls in
+// flight. It returns the first error fn returns, canceling the context of any
+// remaining calls.
+func forEach(parent context.Context, parallelism, n int, fn func(context.Context, int) error) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, parallelism)
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(ctx, i); err != nil {
+				errOnce.Do(func() { firstErr = err })
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = parent.Err()
+	}
+
+	return firstErr
+}
+
+// TopologyDiff wraps the differences between two instances of Topology.
+//
+// Removing (or adding) an application also reports the removal (or addition)
+// of its regions and instances; the same holds true for regions.
+type TopologyDiff struct {
+	// From and To denote the capture times of the compared topologies.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	AddedApps   []string `json:"added_apps,omitempty"`
+	RemovedApps []string `json:"removed_apps,omitempty"`
+
+	AddedRegions   []RegionRef `json:"added_regions,omitempty"`
+	RemovedRegions []RegionRef `json:"removed_regions,omitempty"`
+
+	AddedInstances   []InstanceRef `json:"added_instances,omitempty"`
+	RemovedInstances []InstanceRef `json:"removed_instances,omitempty"`
+}
+
+// RegionRef references a region of an application.
+type RegionRef struct {
+	App    string `json:"app"`
+	Region string `json:"region"`
+}
+
+// InstanceRef references an instance of an application.
+type InstanceRef struct {
+	App    string `json:"app"`
+	Region string `json:"region"`
+	IP     net.IP `json:"ip"`
+}
+
+// IsEmpty reports whether d carries no differences.
+func (d *TopologyDiff) IsEmpty() bool {
+	return len(d.AddedApps) == 0 && len(d.RemovedApps) == 0 &&
+		len(d.AddedRegions) == 0 && len(d.RemovedRegions) == 0 &&
+		len(d.AddedInstances) == 0 && len(d.RemovedInstances) == 0
+}
+
+// Diff returns the differences between a and b; additions are the elements
+// present in b but not in a, while removals are the elements present in a but
+// not in b.
+//
+// Nil values of Topology are treated as empty ones.
+func Diff(a, b *Topology) *TopologyDiff {
+	if a == nil {
+		a = &Topology{}
+	}
+	if b == nil {
+		b = &Topology{}
+	}
+
+	d := &TopologyDiff{
+		From: a.CapturedAt,
+		To:   b.CapturedAt,
+	}
+
+	d.AddedApps, d.AddedRegions, d.AddedInstances = subtract(b, a)
+	d.RemovedApps, d.RemovedRegions, d.RemovedInstances = subtract(a, b)
+
+	return d
+}
+
+// subtract returns the applications, regions and instances of a which are not
+// part of b, sorted.
+func subtract(a, b *Topology) (apps []string, regions []RegionRef, instances []InstanceRef) {
+	for _, app := range sortedKeys(a.Apps) {
+		bRegions, ok := b.Apps[app]
+		if !ok {
+			apps = append(apps, app)
+		}
+
+		aRegions := a.Apps[app]
+		for _, region := range sortedKeys(aRegions) {
+			bIPs, ok := bRegions[region]
+			if !ok {
+				regions = append(regions, RegionRef{App: app, Region: region})
+			}
+
+			for _, ip := range aRegions[region] {
+				if !containsIP(bIPs, ip) {
+					instances = append(instances, InstanceRef{App: app, Region: region, IP: ip})
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, candidate := range ips {
+		if candidate.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortIPs(ips []net.IP) {
+	sort.Slice(ips, func(i, j int) bool {
+		return bytes.Compare(ips[i].To16(), ips[j].To16()) < 0
+	})
+}
+
+// unique returns the unique values of vs, preserving their order.
+func unique(vs []string) (ret []string) {
+	seen := make(map[string]struct{}, len(vs))
+	for _, v := range vs {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			ret = append(ret, v)
+		}
+	}
+
+	return
+}
+
+func ignoreNotFound(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil
+	}
+
+	return err
+}
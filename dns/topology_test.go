@@ -0,0 +1,170 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/dns/dnstest"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestSnapshot(t *testing.T) {
+	s := dnstest.NewServer(&dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "app1",
+				Instances: []dnstest.Instance{
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::3")},
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::1")},
+					{Region: "ams", IP: testutil.ParseIP(t, "fdaa::2")},
+				},
+			},
+			{
+				Name: "app2",
+			},
+		},
+	})
+	t.Cleanup(s.Close)
+
+	before := time.Now()
+
+	t.Cleanup(stub(&Client{Addr: s.Addr}))
+	got, err := Snapshot(context.TODO())
+	testutil.AssertEqual(t, nil, err)
+
+	if got.CapturedAt.Before(before.Add(-time.Second)) {
+		t.Errorf("unexpected capture time: %s", got.CapturedAt)
+	}
+	testutil.AssertEqual(t, map[string]map[string][]net.IP{
+		"app1": {
+			"ams": {testutil.ParseIP(t, "fdaa::2")},
+			"iad": {testutil.ParseIP(t, "fdaa::1"), testutil.ParseIP(t, "fdaa::3")},
+		},
+		"app2": {},
+	}, got.Apps)
+}
+
+func TestSnapshotIsBounded(t *testing.T) {
+	var inFlight, peak int32
+
+	r := &mockResolver{
+		lookupTXT: func(_ context.Context, name string) ([]string, error) {
+			if name == "_apps.internal" {
+				return []string{"a,b,c,d,e,f,g,h"}, nil
+			}
+
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			return nil, nil
+		},
+	}
+
+	_, err := SnapshotOf(context.TODO(), New(r, WithParallelism(2)))
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, true, atomic.LoadInt32(&peak) <= 2)
+}
+
+func TestSnapshotFails(t *testing.T) {
+	s := dnstest.NewServer(&dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "app1",
+				Instances: []dnstest.Instance{
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::1")},
+				},
+			},
+		},
+	})
+	t.Cleanup(s.Close)
+	s.Fail("iad.app1.internal", dnstest.ServerFailure)
+
+	_, err := SnapshotOf(context.TODO(), New(&Client{Addr: s.Addr}))
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var (
+		ip1 = testutil.ParseIP(t, "fdaa::1")
+		ip2 = testutil.ParseIP(t, "fdaa::2")
+		ip3 = testutil.ParseIP(t, "fdaa::3")
+	)
+
+	a := &Topology{
+		CapturedAt: time.Unix(1, 0).UTC(),
+		Apps: map[string]map[string][]net.IP{
+			"app1": {"iad": {ip1}, "ams": {ip2}},
+			"app2": {"cdg": {ip3}},
+		},
+	}
+	b := &Topology{
+		CapturedAt: time.Unix(2, 0).UTC(),
+		Apps: map[string]map[string][]net.IP{
+			"app1": {"iad": {ip1, ip2}},
+			"app3": {"syd": {ip3}},
+		},
+	}
+
+	got := Diff(a, b)
+	testutil.AssertEqual(t, &TopologyDiff{
+		From:        a.CapturedAt,
+		To:          b.CapturedAt,
+		AddedApps:   []string{"app3"},
+		RemovedApps: []string{"app2"},
+		AddedRegions: []RegionRef{
+			{App: "app3", Region: "syd"},
+		},
+		RemovedRegions: []RegionRef{
+			{App: "app1", Region: "ams"},
+			{App: "app2", Region: "cdg"},
+		},
+		AddedInstances: []InstanceRef{
+			{App: "app1", Region: "iad", IP: ip2},
+			{App: "app3", Region: "syd", IP: ip3},
+		},
+		RemovedInstances: []InstanceRef{
+			{App: "app1", Region: "ams", IP: ip2},
+			{App: "app2", Region: "cdg", IP: ip3},
+		},
+	}, got)
+	testutil.AssertEqual(t, false, got.IsEmpty())
+	testutil.AssertEqual(t, true, Diff(a, a).IsEmpty())
+	testutil.AssertEqual(t, true, Diff(nil, nil).IsEmpty())
+
+	data, err := json.Marshal(got)
+	testutil.AssertEqual(t, nil, err)
+
+	var decoded TopologyDiff
+	testutil.AssertEqual(t, nil, json.Unmarshal(data, &decoded))
+	testutil.AssertEqual(t, got, &decoded)
+}
+
+func TestTopologyJSON(t *testing.T) {
+	exp := &Topology{
+		CapturedAt: time.Unix(1, 0).UTC(),
+		Apps: map[string]map[string][]net.IP{
+			"app1": {"iad": {testutil.ParseIP(t, "fdaa::1")}},
+		},
+	}
+
+	data, err := json.Marshal(exp)
+	testutil.AssertEqual(t, nil, err)
+
+	var got Topology
+	testutil.AssertEqual(t, nil, json.Unmarshal(data, &got))
+	testutil.AssertEqual(t, exp, &got)
+}
@@ -0,0 +1,158 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/azazeal/fly/env"
+)
+
+// Default values of the backoff the Wait family of functions use.
+const (
+	// DefaultMinBackoff denotes the default amount of time the Wait family of
+	// functions wait for after their first unsuccessful attempt.
+	DefaultMinBackoff = 100 * time.Millisecond
+
+	// DefaultMaxBackoff denotes the default maximum amount of time the Wait
+	// family of functions wait for in between attempts.
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// WithBackoff sets the bounds of the exponential backoff the Wait family of
+// functions use in between attempts on behalf of the instance. Non-positive
+// values of min are treated as DefaultMinBackoff and values of max less than
+// min are treated as min.
+func WithBackoff(min, max time.Duration) Option {
+	return func(w *wrapper) {
+		if min <= 0 {
+			min = DefaultMinBackoff
+		}
+		if max < min {
+			max = min
+		}
+
+		w.minBackoff, w.maxBackoff = min, max
+	}
+}
+
+// WaitForInstances polls, with exponential backoff (see WithBackoff), until at
+// least min instances of the named application in the given region resolve and
+// returns them.
+//
+// Should ctx be done before that happens, WaitForInstances returns the last
+// error it encountered.
+func WaitForInstances(ctx context.Context, appName, region string, min int) ([]net.IP, error) {
+	return WaitForInstancesOf(ctx, global, appName, region, min)
+}
+
+// WaitForInstancesOf is like WaitForInstances but polls d.
+func WaitForInstancesOf(ctx context.Context, d DNS, appName, region string, min int) (ips []net.IP, err error) {
+	err = poll(ctx, d, func(ctx context.Context) (done bool, err error) {
+		if ips, err = d.Instances(ctx, appName, region); err != nil {
+			return false, err
+		} else if len(ips) < min {
+			return false, fmt.Errorf("dns: %d of %d instances of %s resolve", len(ips), min, appName)
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		ips = nil
+	}
+
+	return
+}
+
+// WaitForSelf polls, with exponential backoff (see WithBackoff), until the IPv6
+// address of the local instance resolves under the global name of the current
+// application (see env.AppName).
+//
+// Should ctx be done before that happens, WaitForSelf returns the last error
+// it encountered.
+func WaitForSelf(ctx context.Context) error {
+	return WaitForSelfOf(ctx, global)
+}
+
+// WaitForSelfOf is like WaitForSelf but polls d.
+func WaitForSelfOf(ctx context.Context, d DNS) error {
+	appName := env.AppName()
+	if appName == "" {
+		return ErrNoAppName
+	}
+
+	return poll(ctx, d, func(ctx context.Context) (bool, error) {
+		self, err := d.PrivateIP(ctx)
+		if err != nil {
+			return false, err
+		} else if self == nil {
+			return false, fmt.Errorf("dns: private IP not defined")
+		}
+
+		ips, err := d.Instances(ctx, appName, "")
+		if err != nil {
+			return false, err
+		} else if !containsIP(ips, self) {
+			return false, fmt.Errorf("dns: %s does not resolve under %s", self, appName)
+		}
+
+		return true, nil
+	})
+}
+
+// backoffOf returns the bounds of the backoff d was configured with, should New
+// have returned it, or the default ones otherwise.
+func backoffOf(d DNS) (min, max time.Duration) {
+	if w, ok := d.(*wrapper); ok {
+		return w.minBackoff, w.maxBackoff
+	}
+
+	return DefaultMinBackoff, DefaultMaxBackoff
+}
+
+// poll calls fn, with the exponential backoff of d, until it reports done or
+// until ctx is done. In the latter case, poll returns the last error fn
+// returned.
+func poll(ctx context.Context, d DNS, fn func(context.Context) (bool, error)) error {
+	minBackoff, maxBackoff := backoffOf(d)
+
+	var lastErr error
+	for backoff := minBackoff; ; {
+		done, err := fn(ctx)
+		if done {
+			return nil
+		} else if err != nil && !expired(ctx) {
+			lastErr = err // errors due to ctx expiring carry no information
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			if lastErr == nil {
+				lastErr = ctx.Err()
+			}
+
+			return lastErr
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// expired reports whether ctx is done or past its deadline; I/O deadlines
+// derived from the deadline of ctx may expire before ctx itself reports so.
+func expired(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+
+	deadline, ok := ctx.Deadline()
+
+	return ok && !time.Now().Before(deadline)
+}
@@ -0,0 +1,165 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/dns/dnstest"
+	"github.com/azazeal/fly/env"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestWaitForInstances(t *testing.T) {
+	s := dnstest.NewServer(nil)
+	t.Cleanup(s.Close)
+
+	d := New(&Client{Addr: s.Addr}, WithBackoff(time.Millisecond, 10*time.Millisecond))
+
+	topo := &dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "db",
+				Instances: []dnstest.Instance{
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::1")},
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::2")},
+				},
+			},
+		},
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.SetTopology(topo)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	ips, err := WaitForInstancesOf(ctx, d, "db", "iad", 2)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, 2, len(ips))
+}
+
+func TestWaitForInstancesTimeout(t *testing.T) {
+	s := dnstest.NewServer(&dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "db",
+				Instances: []dnstest.Instance{
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::1")},
+				},
+			},
+		},
+	})
+	t.Cleanup(s.Close)
+
+	d := New(&Client{Addr: s.Addr}, WithBackoff(time.Millisecond, 10*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+
+	ips, err := WaitForInstancesOf(ctx, d, "db", "iad", 2)
+	testutil.AssertEqual(t, []net.IP(nil), ips)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 instances") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// lookup errors take precedence over context ones
+	s.Fail("", dnstest.Refused)
+
+	ctx, cancel = context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = WaitForInstancesOf(ctx, d, "db", "iad", 1)
+
+	var dnsErr *net.DNSError
+	testutil.AssertEqual(t, true, errors.As(err, &dnsErr))
+}
+
+func TestWaitForSelf(t *testing.T) {
+	self := testutil.ParseIP(t, "fdaa::2")
+
+	s := dnstest.NewServer(&dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "app1",
+				Instances: []dnstest.Instance{
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::1")},
+				},
+			},
+		},
+		LocalIP: self,
+	})
+	t.Cleanup(s.Close)
+
+	t.Setenv(env.AppNameKey, "app1")
+
+	d := New(&Client{Addr: s.Addr},
+		WithBackoff(time.Millisecond, 10*time.Millisecond),
+		WithPrivateIPSources(SourceDNS))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+
+	err := WaitForSelfOf(ctx, d)
+	if err == nil || !strings.Contains(err.Error(), "does not resolve") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	topo := &dnstest.Topology{
+		Apps: []dnstest.App{
+			{
+				Name: "app1",
+				Instances: []dnstest.Instance{
+					{Region: "iad", IP: testutil.ParseIP(t, "fdaa::1")},
+					{Region: "iad", IP: self},
+				},
+			},
+		},
+		LocalIP: self,
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.SetTopology(topo)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	testutil.AssertEqual(t, nil, WaitForSelfOf(ctx, d))
+}
+
+func TestWaitForSelfWithoutAppName(t *testing.T) {
+	t.Setenv(env.AppNameKey, "")
+
+	testutil.AssertEqual(t, ErrNoAppName, WaitForSelf(context.TODO()))
+}
+
+func TestWithBackoff(t *testing.T) {
+	cases := []struct {
+		min, max       time.Duration
+		expMin, expMax time.Duration
+	}{
+		0: {time.Millisecond, time.Second, time.Millisecond, time.Second},
+		1: {time.Second, time.Millisecond, time.Second, time.Second},
+		2: {0, 0, DefaultMinBackoff, DefaultMinBackoff},
+		3: {-time.Second, time.Second, DefaultMinBackoff, time.Second},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			min, max := backoffOf(New(nil, WithBackoff(kase.min, kase.max)))
+
+			testutil.AssertEqual(t, kase.expMin, min)
+			testutil.AssertEqual(t, kase.expMax, max)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// DefaultWatchInterval denotes the default interval at which Watch polls.
+const DefaultWatchInterval = 5 * time.Second
+
+// Membership wraps the outcome of a lookup Watch performed.
+type Membership struct {
+	// IPs denotes the sorted IPv6 addresses of the instances the lookup
+	// returned.
+	IPs []net.IP
+
+	// Err denotes the error the lookup returned, if any.
+	Err error
+}
+
+// Equal reports whether m and other carry the same addresses and whether they
+// both carry an error or not.
+func (m Membership) Equal(other Membership) bool {
+	if (m.Err == nil) != (other.Err == nil) || len(m.IPs) != len(other.IPs) {
+		return false
+	}
+
+	for i := range m.IPs {
+		if !m.IPs[i].Equal(other.IPs[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Watch polls, at the given interval, for the instances of the named
+// application in the given region (or all of them, should region be empty).
+//
+// The returned channel receives the outcome of the first lookup and of every
+// one which differs from its predecessor; names which do not exist are treated
+// as having no instances. The channel is closed once ctx is done.
+//
+// Should interval be non-positive, DefaultWatchInterval is used.
+func Watch(ctx context.Context, appName, region string, interval time.Duration) <-chan Membership {
+	return WatchOf(ctx, global, appName, region, interval)
+}
+
+// WatchOf is like Watch but polls d.
+func WatchOf(ctx context.Context, d DNS, appName, region string, interval time.Duration) <-chan Membership {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	ch := make(chan Membership, 1)
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var (
+			last  Membership
+			first = true
+		)
+		for {
+			ips, err := d.Instances(ctx, appName, region)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err = ignoreNotFound(err); err == nil {
+				sortIPs(ips)
+			} else {
+				ips = nil
+			}
+			cur := Membership{IPs: ips, Err: err}
+
+			if first || !cur.Equal(last) {
+				select {
+				case ch <- cur:
+				case <-ctx.Done():
+					return
+				}
+
+				first, last = false, cur
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
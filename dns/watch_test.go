@@ -0,0 +1,90 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/dns/dnstest"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestWatch(t *testing.T) {
+	var (
+		ip1 = testutil.ParseIP(t, "fdaa::1")
+		ip2 = testutil.ParseIP(t, "fdaa::2")
+	)
+
+	topology := func(ips ...net.IP) *dnstest.Topology {
+		app := dnstest.App{Name: "app1"}
+		for _, ip := range ips {
+			app.Instances = append(app.Instances, dnstest.Instance{Region: "iad", IP: ip})
+		}
+
+		return &dnstest.Topology{Apps: []dnstest.App{app}}
+	}
+
+	s := dnstest.NewServer(topology(ip2, ip1))
+	t.Cleanup(s.Close)
+
+	h, err := NewHandle(New(&Client{Addr: s.Addr}), "app1")
+	testutil.AssertEqual(t, nil, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	ch := h.Watch(ctx, "iad", 5*time.Millisecond)
+
+	m := receive(t, ch)
+	testutil.AssertEqual(t, []net.IP{ip1, ip2}, m.IPs)
+	testutil.AssertEqual(t, nil, m.Err)
+
+	s.SetTopology(topology(ip1))
+	m = receive(t, ch)
+	testutil.AssertEqual(t, []net.IP{ip1}, m.IPs)
+
+	s.SetTopology(topology())
+	m = receive(t, ch)
+	testutil.AssertEqual(t, 0, len(m.IPs))
+	testutil.AssertEqual(t, nil, m.Err)
+
+	s.Fail("", dnstest.ServerFailure)
+	m = receive(t, ch)
+	testutil.AssertEqual(t, false, m.Err == nil)
+
+	cancel()
+	for range ch {
+		continue // drain until closed
+	}
+}
+
+func TestMembershipEqual(t *testing.T) {
+	var (
+		ip1 = testutil.ParseIP(t, "fdaa::1")
+		ip2 = testutil.ParseIP(t, "fdaa::2")
+	)
+
+	testutil.AssertEqual(t, true, Membership{}.Equal(Membership{}))
+	testutil.AssertEqual(t, true, Membership{IPs: []net.IP{ip1}}.Equal(Membership{IPs: []net.IP{ip1.To16()}}))
+	testutil.AssertEqual(t, false, Membership{IPs: []net.IP{ip1}}.Equal(Membership{IPs: []net.IP{ip2}}))
+	testutil.AssertEqual(t, false, Membership{IPs: []net.IP{ip1}}.Equal(Membership{}))
+	testutil.AssertEqual(t, false, Membership{Err: context.Canceled}.Equal(Membership{}))
+}
+
+func receive(t *testing.T, ch <-chan Membership) Membership {
+	t.Helper()
+
+	select {
+	case m, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed")
+		}
+
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for membership")
+
+		return Membership{}
+	}
+}
@@ -21,11 +21,17 @@ const (
 	// RegionKey denotes the name of the environment variable which reports
 	// the instance's region.
 	RegionKey = "FLY_REGION"
+
+	// PrivateIPKey denotes the name of the environment variable which reports
+	// the instance's private (6PN) IP address.
+	PrivateIPKey = "FLY_PRIVATE_IP"
 )
 
 var (
-	keys = []string{AppNameKey, AllocIDKey, PublicIPKey, RegionKey}
+	keys = []string{AppNameKey, AllocIDKey, PublicIPKey, RegionKey, PrivateIPKey}
 
+	// lookups does not include LookupPrivateIP since not all fly environments
+	// define PrivateIPKey.
 	lookups = []func() (string, bool){
 		LookupAppName,
 		LookupAllocID,
@@ -34,7 +40,8 @@ var (
 	}
 )
 
-// IsSet reports whether all fly-related environment variables are defined.
+// IsSet reports whether all fly-related environment variables, bar
+// PrivateIPKey, are defined.
 func IsSet() bool {
 	for _, fn := range lookups {
 		if _, ok := fn(); !ok {
@@ -105,3 +112,13 @@ func Region() (v string) {
 func LookupRegion() (string, bool) {
 	return os.LookupEnv(RegionKey)
 }
+
+// PrivateIP is shorthand for os.Getenv(PrivateIPKey).
+func PrivateIP() string {
+	return os.Getenv(PrivateIPKey)
+}
+
+// LookupPrivateIP is shorthand for os.LookupEnv(PrivateIPKey).
+func LookupPrivateIP() (string, bool) {
+	return os.LookupEnv(PrivateIPKey)
+}
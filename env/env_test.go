@@ -57,6 +57,7 @@ func forEachCase(t *testing.T, fn func(*testing.T, *testCase)) {
 		kase := cases[caseIndex]
 
 		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			testutil.UnsetEnv(t, "FLY_")
 			fn(t, kase)
 		})
 	}
@@ -64,10 +65,11 @@ func forEachCase(t *testing.T, fn func(*testing.T, *testCase)) {
 
 func TestGetters(t *testing.T) {
 	funcs := map[string]func() string{
-		AppNameKey:  AppName,
-		AllocIDKey:  AllocID,
-		PublicIPKey: PublicIP,
-		RegionKey:   Region,
+		AppNameKey:   AppName,
+		AllocIDKey:   AllocID,
+		PublicIPKey:  PublicIP,
+		RegionKey:    Region,
+		PrivateIPKey: PrivateIP,
 	}
 
 	for key := range funcs {
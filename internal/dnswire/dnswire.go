@@ -0,0 +1,420 @@
+// Package dnswire implements encoding and decoding of DNS messages, as
+// described in RFC 1035.
+//
+// Only the subset of the protocol fly's internal DNS makes use of is covered.
+package dnswire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The set of supported record types.
+const (
+	TypeA    uint16 = 1
+	TypeTXT  uint16 = 16
+	TypeAAAA uint16 = 28
+)
+
+// ClassINET denotes the Internet class.
+const ClassINET uint16 = 1
+
+// The set of response codes.
+const (
+	RCodeSuccess        uint8 = 0
+	RCodeFormatError    uint8 = 1
+	RCodeServerFailure  uint8 = 2
+	RCodeNameError      uint8 = 3
+	RCodeNotImplemented uint8 = 4
+	RCodeRefused        uint8 = 5
+)
+
+// MaxUDPSize denotes the maximum size of a message sent over UDP without
+// EDNS(0).
+const MaxUDPSize = 512
+
+const (
+	headerLen   = 12
+	maxLabelLen = 63
+	maxNameLen  = 255
+	maxPointers = 10
+)
+
+var (
+	// ErrShort is returned by Parse for messages that end prematurely.
+	ErrShort = errors.New("dnswire: message too short")
+
+	// ErrName is returned for invalid domain names.
+	ErrName = errors.New("dnswire: invalid name")
+)
+
+// Header wraps the header section of a message.
+type Header struct {
+	ID                 uint16
+	Response           bool
+	Opcode             uint8
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	RCode              uint8
+}
+
+func (h *Header) flags() (f uint16) {
+	if h.Response {
+		f |= 1 << 15
+	}
+	f |= uint16(h.Opcode&0xf) << 11
+	if h.Authoritative {
+		f |= 1 << 10
+	}
+	if h.Truncated {
+		f |= 1 << 9
+	}
+	if h.RecursionDesired {
+		f |= 1 << 8
+	}
+	if h.RecursionAvailable {
+		f |= 1 << 7
+	}
+	f |= uint16(h.RCode & 0xf)
+
+	return
+}
+
+func (h *Header) setFlags(f uint16) {
+	h.Response = f&(1<<15) != 0
+	h.Opcode = uint8(f>>11) & 0xf
+	h.Authoritative = f&(1<<10) != 0
+	h.Truncated = f&(1<<9) != 0
+	h.RecursionDesired = f&(1<<8) != 0
+	h.RecursionAvailable = f&(1<<7) != 0
+	h.RCode = uint8(f & 0xf)
+}
+
+// Question wraps an entry of the question section of a message.
+type Question struct {
+	// Name denotes the fully-qualified name of the question.
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// Resource wraps an entry of the answer, authority or additional sections of a
+// message.
+type Resource struct {
+	// Name denotes the fully-qualified name of the resource.
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+
+	// Data denotes the raw RDATA of the resource.
+	Data []byte
+}
+
+// NewIP returns an A or AAAA (depending on the family of ip) Resource for the
+// given name, TTL and IP.
+func NewIP(name string, ttl uint32, ip net.IP) Resource {
+	r := Resource{
+		Name:  name,
+		Class: ClassINET,
+		TTL:   ttl,
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		r.Type = TypeA
+		r.Data = append(r.Data, ip4...)
+	} else {
+		r.Type = TypeAAAA
+		r.Data = append(r.Data, ip.To16()...)
+	}
+
+	return r
+}
+
+// NewTXT returns a TXT Resource for the given name, TTL and text.
+//
+// Text longer than 255 bytes is split across multiple character strings.
+func NewTXT(name string, ttl uint32, text string) Resource {
+	r := Resource{
+		Name:  name,
+		Type:  TypeTXT,
+		Class: ClassINET,
+		TTL:   ttl,
+	}
+
+	for {
+		chunk := text
+		if len(chunk) > 255 {
+			chunk = chunk[:255]
+		}
+
+		r.Data = append(r.Data, byte(len(chunk)))
+		r.Data = append(r.Data, chunk...)
+
+		if text = text[len(chunk):]; text == "" {
+			break
+		}
+	}
+
+	return r
+}
+
+// IP returns the address an A or AAAA Resource carries.
+//
+// IP returns nil for any other Resource.
+func (r *Resource) IP() net.IP {
+	switch {
+	case r.Type == TypeA && len(r.Data) == net.IPv4len:
+		return net.IPv4(r.Data[0], r.Data[1], r.Data[2], r.Data[3])
+	case r.Type == TypeAAAA && len(r.Data) == net.IPv6len:
+		return append(net.IP(nil), r.Data...)
+	default:
+		return nil
+	}
+}
+
+// Text returns the concatenation of the character strings a TXT Resource
+// carries.
+func (r *Resource) Text() (string, error) {
+	if r.Type != TypeTXT {
+		return "", fmt.Errorf("dnswire: not a TXT resource (type %d)", r.Type)
+	}
+
+	var sb strings.Builder
+	for data := r.Data; len(data) > 0; {
+		l := int(data[0])
+		if 1+l > len(data) {
+			return "", ErrShort
+		}
+
+		sb.Write(data[1 : 1+l])
+		data = data[1+l:]
+	}
+
+	return sb.String(), nil
+}
+
+// Message wraps a DNS message.
+type Message struct {
+	Header
+
+	Questions   []Question
+	Answers     []Resource
+	Authorities []Resource
+	Additionals []Resource
+}
+
+// Pack returns the wire representation of m.
+//
+// Pack does not compress names.
+func (m *Message) Pack() ([]byte, error) {
+	b := make([]byte, headerLen, MaxUDPSize)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+	binary.BigEndian.PutUint16(b[2:], m.flags())
+
+	counts := []int{len(m.Questions), len(m.Answers), len(m.Authorities), len(m.Additionals)}
+	for i, c := range counts {
+		if c > 0xffff {
+			return nil, fmt.Errorf("dnswire: too many entries in section %d", i)
+		}
+		binary.BigEndian.PutUint16(b[4+2*i:], uint16(c))
+	}
+
+	var err error
+	for _, q := range m.Questions {
+		if b, err = appendName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = appendUint16(b, q.Type)
+		b = appendUint16(b, q.Class)
+	}
+
+	for _, section := range [][]Resource{m.Answers, m.Authorities, m.Additionals} {
+		for _, r := range section {
+			if b, err = appendResource(b, &r); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return b, nil
+}
+
+func appendResource(b []byte, r *Resource) ([]byte, error) {
+	if len(r.Data) > 0xffff {
+		return nil, fmt.Errorf("dnswire: data of %q too long", r.Name)
+	}
+
+	b, err := appendName(b, r.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	b = appendUint16(b, r.Type)
+	b = appendUint16(b, r.Class)
+	b = appendUint16(b, uint16(r.TTL>>16))
+	b = appendUint16(b, uint16(r.TTL))
+	b = appendUint16(b, uint16(len(r.Data)))
+
+	return append(b, r.Data...), nil
+}
+
+func appendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if len(name)+1 > maxNameLen {
+		return nil, fmt.Errorf("%w: %q is too long", ErrName, name)
+	}
+
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if l := len(label); l == 0 || l > maxLabelLen {
+				return nil, fmt.Errorf("%w: %q contains an invalid label", ErrName, name)
+			}
+
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+
+	return append(b, 0), nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// Parse parses the wire representation of a Message.
+func Parse(b []byte) (*Message, error) {
+	if len(b) < headerLen {
+		return nil, ErrShort
+	}
+
+	m := &Message{}
+	m.ID = binary.BigEndian.Uint16(b[0:])
+	m.setFlags(binary.BigEndian.Uint16(b[2:]))
+
+	var (
+		qdCount = int(binary.BigEndian.Uint16(b[4:]))
+		anCount = int(binary.BigEndian.Uint16(b[6:]))
+		nsCount = int(binary.BigEndian.Uint16(b[8:]))
+		arCount = int(binary.BigEndian.Uint16(b[10:]))
+	)
+
+	off := headerLen
+	for i := 0; i < qdCount; i++ {
+		var (
+			q   Question
+			err error
+		)
+		if q.Name, off, err = parseName(b, off); err != nil {
+			return nil, err
+		}
+		if off+4 > len(b) {
+			return nil, ErrShort
+		}
+		q.Type = binary.BigEndian.Uint16(b[off:])
+		q.Class = binary.BigEndian.Uint16(b[off+2:])
+		off += 4
+
+		m.Questions = append(m.Questions, q)
+	}
+
+	var err error
+	if m.Answers, off, err = parseResources(b, off, anCount); err != nil {
+		return nil, err
+	}
+	if m.Authorities, off, err = parseResources(b, off, nsCount); err != nil {
+		return nil, err
+	}
+	if m.Additionals, _, err = parseResources(b, off, arCount); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func parseResources(b []byte, off, count int) (rs []Resource, _ int, err error) {
+	for i := 0; i < count; i++ {
+		var r Resource
+		if r.Name, off, err = parseName(b, off); err != nil {
+			return nil, off, err
+		}
+		if off+10 > len(b) {
+			return nil, off, ErrShort
+		}
+
+		r.Type = binary.BigEndian.Uint16(b[off:])
+		r.Class = binary.BigEndian.Uint16(b[off+2:])
+		r.TTL = binary.BigEndian.Uint32(b[off+4:])
+		l := int(binary.BigEndian.Uint16(b[off+8:]))
+		off += 10
+
+		if off+l > len(b) {
+			return nil, off, ErrShort
+		}
+		r.Data = append([]byte(nil), b[off:off+l]...)
+		off += l
+
+		rs = append(rs, r)
+	}
+
+	return rs, off, nil
+}
+
+// parseName parses the, possibly compressed, name starting at off. It returns
+// the name and the offset right after it.
+func parseName(b []byte, off int) (string, int, error) {
+	var (
+		sb       strings.Builder
+		next     = -1
+		pointers int
+	)
+
+	for {
+		if off >= len(b) {
+			return "", 0, ErrShort
+		}
+
+		switch l := int(b[off]); l & 0xc0 {
+		case 0x00:
+			if l == 0 {
+				if next < 0 {
+					next = off + 1
+				}
+				if sb.Len() == 0 {
+					sb.WriteByte('.')
+				}
+
+				return sb.String(), next, nil
+			}
+
+			if off+1+l > len(b) {
+				return "", 0, ErrShort
+			}
+			sb.Write(b[off+1 : off+1+l])
+			sb.WriteByte('.')
+			if sb.Len() > maxNameLen {
+				return "", 0, fmt.Errorf("%w: name too long", ErrName)
+			}
+			off += 1 + l
+		case 0xc0:
+			if off+1 >= len(b) {
+				return "", 0, ErrShort
+			}
+			if pointers++; pointers > maxPointers {
+				return "", 0, fmt.Errorf("%w: too many compression pointers", ErrName)
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+		default:
+			return "", 0, fmt.Errorf("%w: unsupported label type", ErrName)
+		}
+	}
+}
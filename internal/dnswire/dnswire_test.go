@@ -0,0 +1,111 @@
+package dnswire
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestPackParse(t *testing.T) {
+	long := strings.Repeat("a", 300)
+
+	exp := &Message{
+		Header: Header{
+			ID:                 0xbeef,
+			Response:           true,
+			Authoritative:      true,
+			RecursionDesired:   true,
+			RecursionAvailable: true,
+			RCode:              RCodeSuccess,
+		},
+		Questions: []Question{
+			{Name: "_apps.internal.", Type: TypeTXT, Class: ClassINET},
+		},
+		Answers: []Resource{
+			NewTXT("_apps.internal.", 5, "app1,app2"),
+			NewTXT("_apps.internal.", 5, long),
+			NewIP("global.app1.internal.", 30, testutil.ParseIP(t, "fdaa:0:22b7:a7b:abd:aa3c:6498:2")),
+			NewIP("global.app1.internal.", 30, testutil.ParseIP(t, "10.0.0.1")),
+		},
+	}
+
+	data, err := exp.Pack()
+	testutil.AssertEqual(t, nil, err)
+
+	got, err := Parse(data)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, exp, got)
+
+	txt, err := got.Answers[1].Text()
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, long, txt)
+
+	testutil.AssertEqual(t, testutil.ParseIP(t, "fdaa:0:22b7:a7b:abd:aa3c:6498:2"), got.Answers[2].IP())
+	testutil.AssertEqual(t, testutil.ParseIP(t, "10.0.0.1"), got.Answers[3].IP())
+	testutil.AssertEqual(t, net.IP(nil), got.Answers[0].IP())
+}
+
+func TestParseCompressed(t *testing.T) {
+	data := []byte{
+		0x12, 0x34, 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0,
+		// question: fly-local-6pn. AAAA IN
+		13, 'f', 'l', 'y', '-', 'l', 'o', 'c', 'a', 'l', '-', '6', 'p', 'n', 0,
+		0, 28, 0, 1,
+		// answer: pointer to offset 12
+		0xc0, 12,
+		0, 28, 0, 1, 0, 0, 0, 5, 0, 16,
+		0xfd, 0xaa, 0, 0, 0x22, 0xb7, 0x0a, 0x7b, 0x0a, 0xbd, 0xaa, 0x3c, 0x64, 0x98, 0, 2,
+	}
+
+	got, err := Parse(data)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, "fly-local-6pn.", got.Answers[0].Name)
+	testutil.AssertEqual(t, uint32(5), got.Answers[0].TTL)
+	testutil.AssertEqual(t, testutil.ParseIP(t, "fdaa:0:22b7:a7b:abd:aa3c:6498:2"), got.Answers[0].IP())
+	testutil.AssertEqual(t, true, got.Response)
+	testutil.AssertEqual(t, true, got.RecursionAvailable)
+}
+
+func TestParseRejectsPointerLoops(t *testing.T) {
+	data := []byte{
+		0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0,
+		0xc0, 12, 0, 1, 0, 1,
+	}
+
+	_, err := Parse(data)
+	testutil.AssertEqual(t, true, errors.Is(err, ErrName))
+}
+
+func TestParseShort(t *testing.T) {
+	exp := &Message{
+		Questions: []Question{
+			{Name: "_peer.internal.", Type: TypeTXT, Class: ClassINET},
+		},
+		Answers: []Resource{
+			NewTXT("_peer.internal.", 5, "peer1"),
+		},
+	}
+
+	data, err := exp.Pack()
+	testutil.AssertEqual(t, nil, err)
+
+	for i := 0; i < len(data); i++ {
+		if _, err := Parse(data[:i]); !errors.Is(err, ErrShort) {
+			t.Fatalf("parsing %d bytes: expected ErrShort, got %v", i, err)
+		}
+	}
+}
+
+func TestPackInvalidName(t *testing.T) {
+	m := &Message{
+		Questions: []Question{
+			{Name: "a..internal", Type: TypeA, Class: ClassINET},
+		},
+	}
+
+	_, err := m.Pack()
+	testutil.AssertEqual(t, true, errors.Is(err, ErrName))
+}
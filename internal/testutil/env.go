@@ -0,0 +1,26 @@
+package testutil
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// UnsetEnv unsets, for the duration of the test, the environment variables
+// whose names start with prefix, so that the test does not depend on the
+// environment it runs in.
+func UnsetEnv(tb testing.TB, prefix string) {
+	tb.Helper()
+
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		tb.Setenv(key, "") // registers the restoration of the original value
+		if err := os.Unsetenv(key); err != nil {
+			tb.Fatalf("failed unsetting %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package request
+
+import (
+	"context"
+	"net/http"
+)
+
+type infoKey struct{}
+
+// NewContext returns a copy of ctx which carries i.
+func NewContext(ctx context.Context, i Info) context.Context {
+	return context.WithValue(ctx, infoKey{}, i)
+}
+
+// FromContext returns the Info ctx carries, if any.
+func FromContext(ctx context.Context) (i Info, ok bool) {
+	i, ok = ctx.Value(infoKey{}).(Info)
+
+	return
+}
+
+type idKey struct{}
+
+// NewIDContext returns a copy of ctx which carries the given request ID.
+func NewIDContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// IDFromContext returns the request ID ctx carries, if any; that is the one
+// NewIDContext stored or, failing that, the one of the Info ctx carries.
+func IDFromContext(ctx context.Context) (id string, ok bool) {
+	if id, ok = ctx.Value(idKey{}).(string); ok && id != "" {
+		return
+	}
+
+	i, _ := FromContext(ctx)
+
+	return i.ID, i.ID != ""
+}
+
+type headerKey struct{}
+
+// newHeaderContext returns a copy of ctx which carries the header of the
+// inbound request it belongs to.
+func newHeaderContext(ctx context.Context, h http.Header) context.Context {
+	return context.WithValue(ctx, headerKey{}, h)
+}
+
+// headerFromContext returns the header of the inbound request ctx belongs to,
+// if any.
+func headerFromContext(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headerKey{}).(http.Header)
+
+	return h
+}
+
+// Middleware returns a handler which parses the Info of the requests it
+// serves (see Parse) and stores it, along with their header, in their context
+// before calling next.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := NewContext(r.Context(), Parse(r))
+		ctx = newHeaderContext(ctx, r.Header)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Detach returns a context which carries the Info, the request ID and the
+// inbound header ctx carries, if any, but neither its values, deadline nor
+// cancelation.
+//
+// Detach is meant for goroutines which outlive the request they're spawned
+// from.
+func Detach(ctx context.Context) context.Context {
+	detached := context.Background()
+	if i, ok := FromContext(ctx); ok {
+		detached = NewContext(detached, i)
+	}
+	if id, ok := ctx.Value(idKey{}).(string); ok {
+		detached = NewIDContext(detached, id)
+	}
+	if h := headerFromContext(ctx); h != nil {
+		detached = newHeaderContext(detached, h.Clone())
+	}
+
+	return detached
+}
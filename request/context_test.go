@@ -0,0 +1,85 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestFromContext(t *testing.T) {
+	_, ok := FromContext(context.TODO())
+	testutil.AssertEqual(t, false, ok)
+
+	exp := Info{ID: "id", Valid: FieldID}
+
+	got, ok := FromContext(NewContext(context.TODO(), exp))
+	testutil.AssertEqual(t, true, ok)
+	testutil.AssertEqual(t, exp, got)
+}
+
+func TestIDFromContext(t *testing.T) {
+	_, ok := IDFromContext(context.TODO())
+	testutil.AssertEqual(t, false, ok)
+
+	ctx := NewContext(context.TODO(), Info{ID: "info", Valid: FieldID})
+
+	id, ok := IDFromContext(ctx)
+	testutil.AssertEqual(t, true, ok)
+	testutil.AssertEqual(t, "info", id)
+
+	id, ok = IDFromContext(NewIDContext(ctx, "id"))
+	testutil.AssertEqual(t, true, ok)
+	testutil.AssertEqual(t, "id", id)
+}
+
+func TestMiddleware(t *testing.T) {
+	var (
+		got Info
+		ok  bool
+	)
+	h := Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got, ok = FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("fly-region", "iad") //nolint:canonicalheader // fly dox specify this header
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	testutil.AssertEqual(t, true, ok)
+	testutil.AssertEqual(t, Parse(req), got)
+	testutil.AssertEqual(t, "iad", got.Region)
+}
+
+func TestDetach(t *testing.T) {
+	type key struct{}
+
+	exp := Info{Region: "iad", Valid: FieldRegion}
+
+	parent, cancel := context.WithCancel(context.WithValue(context.TODO(), key{}, "value"))
+	parent = NewContext(parent, exp)
+	cancel()
+
+	ctx := Detach(parent)
+	testutil.AssertEqual(t, nil, ctx.Err())
+	testutil.AssertEqual(t, nil, ctx.Value(key{}))
+
+	got, ok := FromContext(ctx)
+	testutil.AssertEqual(t, true, ok)
+	testutil.AssertEqual(t, exp, got)
+
+	_, ok = FromContext(Detach(context.TODO()))
+	testutil.AssertEqual(t, false, ok)
+
+	header := http.Header{RegionHeader: {"iad"}}
+
+	ctx = Detach(newHeaderContext(NewIDContext(parent, "id"), header))
+	header.Set(RegionHeader, "ams")
+
+	id, ok := IDFromContext(ctx)
+	testutil.AssertEqual(t, true, ok)
+	testutil.AssertEqual(t, "id", id)
+	testutil.AssertEqual(t, "iad", headerFromContext(ctx).Get(RegionHeader))
+}
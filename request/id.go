@@ -0,0 +1,60 @@
+package request
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/azazeal/fly/env"
+)
+
+// crockford denotes the alphabet of Crockford's base32 encoding, which ULIDs
+// use.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// random is the source of randomness NewID uses.
+var random io.Reader = rand.Reader
+
+// NewID returns a new request ID in the format fly uses; that is a ULID
+// followed by a dash and the region the local instance runs in (see
+// env.Region). The region suffix is omitted when the region is unknown.
+func NewID() string {
+	return newID(time.Now(), env.Region())
+}
+
+func newID(now time.Time, region string) string {
+	var data [16]byte
+
+	// 48 bits of milliseconds since the epoch followed by 80 random bits
+	binary.BigEndian.PutUint64(data[:8], uint64(now.UnixMilli())<<16)
+	if _, err := io.ReadFull(random, data[6:]); err != nil {
+		panic(err)
+	}
+
+	var sb strings.Builder
+	sb.Grow(26 + 1 + len(region))
+
+	// 128 bits encode to 26 characters; the first one carries the top 3 bits
+	hi := binary.BigEndian.Uint64(data[:8])
+	lo := binary.BigEndian.Uint64(data[8:])
+	for i := 25; i >= 0; i-- {
+		shift := uint(5 * i)
+
+		var v uint64
+		if shift >= 64 {
+			v = hi >> (shift - 64)
+		} else {
+			v = lo>>shift | hi<<(64-shift)
+		}
+		sb.WriteByte(crockford[v&31])
+	}
+
+	if region != "" {
+		sb.WriteByte('-')
+		sb.WriteString(region)
+	}
+
+	return sb.String()
+}
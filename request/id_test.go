@@ -0,0 +1,41 @@
+package request
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/env"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestNewID(t *testing.T) {
+	t.Setenv(env.RegionKey, "iad")
+
+	id := NewID()
+	testutil.AssertEqual(t, 30, len(id))
+	testutil.AssertEqual(t, true, strings.HasSuffix(id, "-iad"))
+
+	for _, c := range id[:26] {
+		if !strings.ContainsRune(crockford, c) {
+			t.Fatalf("invalid character %q in %q", c, id)
+		}
+	}
+
+	testutil.AssertEqual(t, false, id == NewID())
+}
+
+func TestNewIDEncoding(t *testing.T) {
+	old := random
+	t.Cleanup(func() { random = old })
+
+	// the timestamp of the example in the ULID spec
+	now := time.UnixMilli(1469918176385)
+
+	random = bytes.NewReader(make([]byte, 10))
+	testutil.AssertEqual(t, "01ARYZ6S410000000000000000", newID(now, ""))
+
+	random = bytes.NewReader(bytes.Repeat([]byte{0xff}, 10))
+	testutil.AssertEqual(t, "01ARYZ6S41ZZZZZZZZZZZZZZZZ-ams", newID(now, "ams"))
+}
@@ -0,0 +1,38 @@
+package request
+
+import "net/http"
+
+// IDMiddleware returns a handler which, before calling next, assigns a new ID
+// (see NewID) to the requests which lack one, so that ID always returns a
+// meaningful value (e.g. off fly or for requests which bypass the proxy).
+//
+// The ID of every request is echoed in the respective response and stored,
+// along with the header of the request, in its context (see IDFromContext).
+// Should the context of a request carry an Info (see Middleware), its ID is
+// updated as well.
+func IDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id := ID(r)
+		if id == "" {
+			id = NewID()
+
+			if info, ok := FromContext(ctx); ok {
+				info.ID = id
+				info.Valid |= FieldID
+				ctx = NewContext(ctx, info)
+			}
+
+			r = r.Clone(ctx)
+			r.Header.Set(IDHeader, id)
+		}
+
+		ctx = NewIDContext(ctx, id)
+		ctx = newHeaderContext(ctx, r.Header)
+
+		w.Header().Set(IDHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,56 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/azazeal/fly/env"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestIDMiddleware(t *testing.T) {
+	t.Setenv(env.RegionKey, "iad")
+
+	var got *http.Request
+	h := IDMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+
+	// requests which carry an ID keep it
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("fly-request-id", "existing") //nolint:canonicalheader // fly dox specify this header
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	testutil.AssertEqual(t, "existing", ID(got))
+	testutil.AssertEqual(t, "existing", rec.Header().Get(IDHeader))
+
+	// while the rest are assigned one
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	id := ID(got)
+	testutil.AssertEqual(t, 30, len(id))
+	testutil.AssertEqual(t, "-iad", id[26:])
+	testutil.AssertEqual(t, id, rec.Header().Get(IDHeader))
+	testutil.AssertEqual(t, "", ID(req))
+}
+
+func TestIDMiddlewareUpdatesInfo(t *testing.T) {
+	var got Info
+	h := Middleware(IDMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("fly-region", "iad") //nolint:canonicalheader // fly dox specify this header
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	testutil.AssertEqual(t, rec.Header().Get(IDHeader), got.ID)
+	testutil.AssertEqual(t, true, got.Has(FieldID|FieldRegion))
+}
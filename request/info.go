@@ -0,0 +1,192 @@
+package request
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ForwardedProtoHeader denotes the header carrying the protocol (http or https)
+// the client connected to the edge with.
+const ForwardedProtoHeader = "Fly-Forwarded-Proto"
+
+// ForwardedSSLHeader denotes the header carrying whether the client connected
+// to the edge over TLS ("on") or not ("off").
+const ForwardedSSLHeader = "Fly-Forwarded-Ssl"
+
+// ViaHeader denotes the header carrying the proxies the request went through.
+const ViaHeader = "Via"
+
+// ForwardedForHeader denotes the header carrying the addresses of the client
+// and the proxies the request went through.
+const ForwardedForHeader = "X-Forwarded-For"
+
+// RequestStartHeader denotes the header carrying the time the edge accepted
+// the request at, in the t=<microseconds since the epoch> format.
+const RequestStartHeader = "X-Request-Start"
+
+// Field denotes a bitmask of the fields of Info.
+type Field uint16
+
+// The fields of Info.
+const (
+	FieldID Field = 1 << iota
+	FieldRegion
+	FieldClientIP
+	FieldForwardedPort
+	FieldForwardedProto
+	FieldForwardedSSL
+	FieldVia
+	FieldForwardedFor
+	FieldStart
+)
+
+// Info wraps the metadata the fly proxy attaches to requests.
+//
+// Fields whose headers are either missing or malformed are left to their zero
+// value and are not reported by Valid.
+type Info struct {
+	// ID denotes the ID of the request (see IDHeader).
+	ID string
+
+	// Region denotes the region the request was accepted in (see
+	// RegionHeader).
+	Region string
+
+	// ClientIP denotes the address of the client (see ClientIPHeader).
+	ClientIP net.IP
+
+	// ForwardedPort denotes the port the edge accepted the request on (see
+	// ForwardedPortHeader).
+	ForwardedPort int
+
+	// ForwardedProto denotes the protocol, in lowercase, the client connected
+	// to the edge with (see ForwardedProtoHeader).
+	ForwardedProto string
+
+	// ForwardedSSL reports whether the client connected to the edge over TLS
+	// (see ForwardedSSLHeader).
+	ForwardedSSL bool
+
+	// Via denotes the proxies the request went through, in order (see
+	// ViaHeader).
+	Via []string
+
+	// ForwardedFor denotes the addresses, in order, the X-Forwarded-For header
+	// carries (see ForwardedForHeader). Ports are dropped and entries which
+	// aren't addresses (e.g. unknown) are skipped.
+	ForwardedFor []net.IP
+
+	// Start denotes the time the edge accepted the request at (see
+	// RequestStartHeader).
+	Start time.Time
+
+	// Valid denotes the fields which were present and well-formed.
+	Valid Field
+}
+
+// Has reports whether all of the given fields of i are valid.
+func (i *Info) Has(fields Field) bool {
+	return i.Valid&fields == fields
+}
+
+// Parse returns the Info r carries.
+func Parse(r *http.Request) (i Info) {
+	if i.ID = fetch(r, IDHeader); i.ID != "" {
+		i.Valid |= FieldID
+	}
+
+	if i.Region = fetch(r, RegionHeader); i.Region != "" {
+		i.Valid |= FieldRegion
+	}
+
+	if i.ClientIP = ClientIP(r); i.ClientIP != nil {
+		i.Valid |= FieldClientIP
+	}
+
+	if i.ForwardedPort = ForwardedPort(r); i.ForwardedPort != 0 {
+		i.Valid |= FieldForwardedPort
+	}
+
+	switch proto := strings.ToLower(fetch(r, ForwardedProtoHeader)); proto {
+	case "http", "https":
+		i.ForwardedProto = proto
+		i.Valid |= FieldForwardedProto
+	}
+
+	switch strings.ToLower(fetch(r, ForwardedSSLHeader)) {
+	case "on":
+		i.ForwardedSSL = true
+		i.Valid |= FieldForwardedSSL
+	case "off":
+		i.Valid |= FieldForwardedSSL
+	}
+
+	if i.Via = splitList(r.Header[ViaHeader]); len(i.Via) > 0 {
+		i.Valid |= FieldVia
+	}
+
+	if ips, ok := parseForwardedFor(r.Header[ForwardedForHeader]); ok {
+		i.ForwardedFor = ips
+		i.Valid |= FieldForwardedFor
+	}
+
+	if i.Start = parseRequestStart(fetch(r, RequestStartHeader)); !i.Start.IsZero() {
+		i.Valid |= FieldStart
+	}
+
+	return
+}
+
+// splitList splits the comma-separated values of a header, dropping empty
+// elements.
+func splitList(values []string) (list []string) {
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			if elem = strings.TrimSpace(elem); elem != "" {
+				list = append(list, elem)
+			}
+		}
+	}
+
+	return
+}
+
+// parseForwardedFor parses the given X-Forwarded-For header values, skipping
+// the entries which aren't addresses. It reports false in case none of them
+// are.
+func parseForwardedFor(values []string) (ips []net.IP, ok bool) {
+	for _, elem := range splitList(values) {
+		if ip := parseForwardedAddr(elem); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	return ips, len(ips) > 0
+}
+
+// parseForwardedAddr parses an X-Forwarded-For entry, which may carry a port,
+// returning nil in case it isn't an address (e.g. unknown).
+func parseForwardedAddr(elem string) net.IP {
+	if host, _, err := net.SplitHostPort(elem); err == nil {
+		elem = host
+	}
+
+	return net.ParseIP(elem)
+}
+
+// parseRequestStart parses values of the t=<microseconds> form.
+func parseRequestStart(v string) time.Time {
+	if !strings.HasPrefix(v, "t=") {
+		return time.Time{}
+	}
+
+	us, err := strconv.ParseInt(v[2:], 10, 64)
+	if err != nil || us <= 0 {
+		return time.Time{}
+	}
+
+	return time.UnixMicro(us)
+}
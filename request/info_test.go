@@ -0,0 +1,123 @@
+package request
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestParse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("fly-request-id", "01H9Z7ZJ2ZK1V8Y1Q8X0ZC4J6B-iad") //nolint:canonicalheader // fly dox specify this header
+	req.Header.Set("fly-region", "iad")                                //nolint:canonicalheader // fly dox specify this header
+	req.Header.Set("fly-client-ip", "195.75.14.147")                   //nolint:canonicalheader // fly dox specify this header
+	req.Header.Set("fly-forwarded-port", "443")                        //nolint:canonicalheader // fly dox specify this header
+	req.Header.Set("fly-forwarded-proto", "HTTPS")                     //nolint:canonicalheader // fly dox specify this header
+	req.Header.Set("fly-forwarded-ssl", "on")                          //nolint:canonicalheader // fly dox specify this header
+	req.Header.Add("Via", "2 fly.io")
+	req.Header.Add("Via", "1.1 proxy, ")
+	req.Header.Set("X-Forwarded-For", "195.75.14.147, 6e4:dc69:40ac::822")
+	req.Header.Set("X-Request-Start", "t=1700000000123456")
+
+	got := Parse(req)
+	testutil.AssertEqual(t, Info{
+		ID:             "01H9Z7ZJ2ZK1V8Y1Q8X0ZC4J6B-iad",
+		Region:         "iad",
+		ClientIP:       testutil.ParseIP(t, "195.75.14.147"),
+		ForwardedPort:  443,
+		ForwardedProto: "https",
+		ForwardedSSL:   true,
+		Via:            []string{"2 fly.io", "1.1 proxy"},
+		ForwardedFor: []net.IP{
+			testutil.ParseIP(t, "195.75.14.147"),
+			testutil.ParseIP(t, "6e4:dc69:40ac::822"),
+		},
+		Start: time.UnixMicro(1700000000123456),
+		Valid: FieldID | FieldRegion | FieldClientIP | FieldForwardedPort |
+			FieldForwardedProto | FieldForwardedSSL | FieldVia | FieldForwardedFor | FieldStart,
+	}, got)
+	testutil.AssertEqual(t, true, got.Has(FieldID|FieldStart))
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := []struct {
+		header string
+		value  string
+		valid  Field
+	}{
+		0:  {},
+		1:  {header: IDHeader, value: "", valid: 0},
+		2:  {header: ClientIPHeader, value: "not an ip", valid: 0},
+		3:  {header: ForwardedPortHeader, value: "65536", valid: 0},
+		4:  {header: ForwardedProtoHeader, value: "ftp", valid: 0},
+		5:  {header: ForwardedSSLHeader, value: "off", valid: FieldForwardedSSL},
+		6:  {header: ForwardedSSLHeader, value: "maybe", valid: 0},
+		7:  {header: ViaHeader, value: " , ", valid: 0},
+		8:  {header: ForwardedForHeader, value: "unknown, _hidden", valid: 0},
+		9:  {header: RequestStartHeader, value: "1700000000123456", valid: 0},
+		10: {header: RequestStartHeader, value: "t=soon", valid: 0},
+		11: {header: RequestStartHeader, value: "t=-1", valid: 0},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if kase.header != "" {
+				req.Header.Set(kase.header, kase.value)
+			}
+
+			got := Parse(req)
+			testutil.AssertEqual(t, kase.valid, got.Valid)
+			testutil.AssertEqual(t, false, got.ForwardedSSL)
+			testutil.AssertEqual(t, []net.IP(nil), got.ForwardedFor)
+			testutil.AssertEqual(t, time.Time{}, got.Start)
+		})
+	}
+}
+
+func TestParseForwardedFor(t *testing.T) {
+	cases := []struct {
+		values []string
+		exp    []net.IP
+	}{
+		0: {},
+		1: {values: []string{"unknown"}},
+		2: {
+			values: []string{"195.75.14.147, unknown"},
+			exp:    []net.IP{testutil.ParseIP(t, "195.75.14.147")},
+		},
+		3: {
+			values: []string{"unknown, 2a02:1388::1", "_hidden"},
+			exp:    []net.IP{testutil.ParseIP(t, "2a02:1388::1")},
+		},
+		4: {
+			values: []string{"195.75.14.147:5123", "[2a02:1388::1]:443"},
+			exp: []net.IP{
+				testutil.ParseIP(t, "195.75.14.147"),
+				testutil.ParseIP(t, "2a02:1388::1"),
+			},
+		},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, v := range kase.values {
+				req.Header.Add(ForwardedForHeader, v)
+			}
+
+			got := Parse(req)
+			testutil.AssertEqual(t, kase.exp, got.ForwardedFor)
+			testutil.AssertEqual(t, kase.exp != nil, got.Has(FieldForwardedFor))
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Policy decides whether the proxy headers requests carry are to be trusted,
+// based on the address of the peer that sent them (see http.Request's
+// RemoteAddr).
+//
+// The proxy headers are the Fly-* ones along with the ones Parse and IsSecure
+// read. Should they not be trusted, they're either stripped or replaced with
+// ones derived from the connection itself (see Strip).
+//
+// A nil Policy trusts all requests.
+type Policy struct {
+	// Trusted denotes the networks the peers whose proxy headers are trusted
+	// reside in (e.g. sixpn.Prefix()).
+	Trusted []*net.IPNet
+
+	// Strip, when set, makes the Policy drop the proxy headers of untrusted
+	// requests altogether. Otherwise, the client address of untrusted
+	// requests falls back to the address of their peer.
+	Strip bool
+}
+
+// NewPolicy returns a Policy which trusts the given networks, in CIDR
+// notation, or single addresses.
+func NewPolicy(networks ...string) (*Policy, error) {
+	p := &Policy{
+		Trusted: make([]*net.IPNet, 0, len(networks)),
+	}
+
+	for _, network := range networks {
+		if !strings.Contains(network, "/") {
+			ip := net.ParseIP(network)
+			if ip == nil {
+				return nil, fmt.Errorf("request: invalid trusted network %q", network)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			p.Trusted = append(p.Trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, fmt.Errorf("request: invalid trusted network %q: %w", network, err)
+		}
+		p.Trusted = append(p.Trusted, ipNet)
+	}
+
+	return p, nil
+}
+
+// Trusts reports whether the proxy headers r carries are to be trusted.
+func (p *Policy) Trusts(r *http.Request) bool {
+	if p == nil {
+		return true
+	}
+
+	ip := RemoteIP(r)
+
+	return ip != nil && p.contains(ip)
+}
+
+// contains reports whether ip belongs to the trusted networks of p.
+func (p *Policy) contains(ip net.IP) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, network := range p.Trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP is like the package-level ClientIP but, for untrusted requests,
+// returns either the address of the peer or nil, should p strip.
+func (p *Policy) ClientIP(r *http.Request) net.IP {
+	switch {
+	case p.Trusts(r):
+		return ClientIP(r)
+	case p.Strip:
+		return nil
+	default:
+		return RemoteIP(r)
+	}
+}
+
+// Parse is like the package-level Parse but disregards the proxy headers of
+// untrusted requests.
+func (p *Policy) Parse(r *http.Request) (i Info) {
+	if p.Trusts(r) {
+		return Parse(r)
+	}
+
+	if i.ClientIP = p.ClientIP(r); i.ClientIP != nil {
+		i.Valid |= FieldClientIP
+	}
+
+	return
+}
+
+// Middleware returns a handler which removes the proxy headers of untrusted
+// requests before calling next. Unless p strips, the client address header is
+// then set to the address of the peer.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.Trusts(r) {
+			r = r.Clone(r.Context())
+			stripProxyHeaders(r.Header)
+
+			if ip := p.ClientIP(r); ip != nil {
+				r.Header.Set(ClientIPHeader, ip.String())
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RemoteIP returns the address of the peer which sent r or nil, should its
+// RemoteAddr be malformed.
+func RemoteIP(r *http.Request) net.IP {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return net.ParseIP(host)
+}
+
+func stripProxyHeaders(h http.Header) {
+	for key := range h {
+		switch canonical := http.CanonicalHeaderKey(key); {
+		case strings.HasPrefix(canonical, "Fly-"),
+			canonical == ViaHeader,
+			canonical == ForwardedForHeader,
+			canonical == XForwardedProtoHeader,
+			canonical == RequestStartHeader:
+			delete(h, key)
+		}
+	}
+}
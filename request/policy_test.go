@@ -0,0 +1,88 @@
+package request
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestNewPolicy(t *testing.T) {
+	p, err := NewPolicy("fdaa::/16", "172.16.0.1", "::1")
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, []string{"fdaa::/16", "172.16.0.1/32", "::1/128"}, networks(p))
+
+	for _, invalid := range []string{"", "not an ip", "10.0.0.0/33"} {
+		if _, err := NewPolicy(invalid); err == nil {
+			t.Errorf("%q: expected error", invalid)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	trusting, err := NewPolicy("fdaa::/16")
+	testutil.AssertEqual(t, nil, err)
+
+	stripping := &Policy{Trusted: trusting.Trusted, Strip: true}
+
+	cases := []struct {
+		policy     *Policy
+		remoteAddr string
+		trusts     bool
+		clientIP   string
+	}{
+		0: {nil, "1.2.3.4:1234", true, "195.75.14.147"},
+		1: {trusting, "[fdaa:0:22b7::2]:1234", true, "195.75.14.147"},
+		2: {trusting, "1.2.3.4:1234", false, "1.2.3.4"},
+		3: {trusting, "not an address", false, ""},
+		4: {stripping, "1.2.3.4:1234", false, ""},
+		5: {stripping, "[fdaa::1]:1234", true, "195.75.14.147"},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = kase.remoteAddr
+			req.Header.Set("fly-client-ip", "195.75.14.147") //nolint:canonicalheader // fly dox specify this header
+			req.Header.Set("fly-region", "iad")              //nolint:canonicalheader // fly dox specify this header
+			req.Header.Set("X-Forwarded-For", "195.75.14.147")
+
+			testutil.AssertEqual(t, kase.trusts, kase.policy.Trusts(req))
+
+			var exp net.IP
+			if kase.clientIP != "" {
+				exp = testutil.ParseIP(t, kase.clientIP)
+			}
+			testutil.AssertEqual(t, exp, kase.policy.ClientIP(req))
+
+			info := kase.policy.Parse(req)
+			testutil.AssertEqual(t, exp, info.ClientIP)
+			testutil.AssertEqual(t, kase.trusts, info.Has(FieldRegion))
+
+			var got *http.Request
+			kase.policy.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = r
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			testutil.AssertEqual(t, exp, ClientIP(got))
+			testutil.AssertEqual(t, kase.trusts, Region(got) != "")
+			testutil.AssertEqual(t, kase.trusts, got.Header.Get(ForwardedForHeader) != "")
+
+			// the original request is left untouched
+			testutil.AssertEqual(t, "iad", Region(req))
+		})
+	}
+}
+
+func networks(p *Policy) (ret []string) {
+	for _, n := range p.Trusted {
+		ret = append(ret, n.String())
+	}
+
+	return
+}
@@ -0,0 +1,244 @@
+// Package ratelimit implements per-client rate limiting for applications
+// running behind the fly proxy.
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/azazeal/fly/request"
+)
+
+// Default values of the settings Limiter uses.
+const (
+	// DefaultRate denotes the default number of requests per second each key
+	// is allowed to make, on average.
+	DefaultRate = 10
+
+	// DefaultMaxKeys denotes the default maximum number of keys a Limiter
+	// tracks.
+	DefaultMaxKeys = 10000
+
+	// DefaultIdleTimeout denotes the default amount of time after which the
+	// buckets of keys that have not been seen are evicted.
+	DefaultIdleTimeout = 10 * time.Minute
+)
+
+// KeyFunc returns the key requests are rate limited by. Requests for which a
+// KeyFunc returns an empty key are not rate limited.
+type KeyFunc func(r *http.Request) string
+
+// ClientKey is the default KeyFunc; it returns the address of the client of
+// r (see request.ClientIP), falling back to the address of its peer. IPv6
+// addresses are aggregated to their /64 prefix, since clients are commonly
+// assigned whole /64 networks.
+//
+// Applications which enforce a request.Policy should wrap its ClientIP
+// instead.
+func ClientKey(r *http.Request) string {
+	ip := request.ClientIP(r)
+	if ip == nil {
+		ip = request.RemoteIP(r)
+	}
+
+	return IPKey(ip)
+}
+
+// IPKey returns the key of ip; that is ip itself for IPv4 addresses and the
+// /64 prefix of IPv6 ones. IPKey returns an empty key for nil addresses.
+func IPKey(ip net.IP) string {
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return ip.To4().String()
+	default:
+		return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+	}
+}
+
+// Options wraps the options New accepts.
+type Options struct {
+	// Rate denotes the number of requests per second each key is allowed to
+	// make, on average. Should Rate be zero, DefaultRate is used.
+	Rate float64
+
+	// Burst denotes the maximum number of requests each key is allowed to make
+	// at once. Should Burst be less than 1, 1 is used.
+	Burst int
+
+	// KeyFunc denotes the function requests are keyed by. Should KeyFunc be
+	// nil, ClientKey is used.
+	KeyFunc KeyFunc
+
+	// MaxKeys denotes the maximum number of keys tracked at once. Should
+	// MaxKeys be less than 1, DefaultMaxKeys is used. Once the limit is
+	// reached, the least recently seen keys are evicted.
+	MaxKeys int
+
+	// IdleTimeout denotes the amount of time after which the buckets of keys
+	// that have not been seen are evicted. Should IdleTimeout be
+	// non-positive, DefaultIdleTimeout is used.
+	IdleTimeout time.Duration
+}
+
+// Limiter implements token bucket rate limiting.
+//
+// Limiters are safe for concurrent use.
+type Limiter struct {
+	rate        float64
+	burst       float64
+	keyFunc     KeyFunc
+	maxKeys     int
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex // protects the fields below
+	buckets map[string]*list.Element
+	lru     list.List // of *bucket; most recently seen first
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	seen   time.Time
+}
+
+// New returns a Limiter configured by opts.
+//
+// A nil opts is treated as the zero value of Options; that is a Limiter which
+// allows each key DefaultRate requests per second.
+//
+// New panics in case opts.Rate is negative, infinite or NaN.
+func New(opts *Options) *Limiter {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	rate := opts.Rate
+	if rate == 0 {
+		rate = DefaultRate
+	} else if !(rate > 0) || math.IsInf(rate, 1) {
+		panic("ratelimit: invalid rate")
+	}
+
+	l := &Limiter{
+		rate:        rate,
+		burst:       float64(opts.Burst),
+		keyFunc:     opts.KeyFunc,
+		maxKeys:     opts.MaxKeys,
+		idleTimeout: opts.IdleTimeout,
+		now:         time.Now,
+		buckets:     make(map[string]*list.Element),
+	}
+
+	if l.burst < 1 {
+		l.burst = 1
+	}
+	if l.keyFunc == nil {
+		l.keyFunc = ClientKey
+	}
+	if l.maxKeys < 1 {
+		l.maxKeys = DefaultMaxKeys
+	}
+	if l.idleTimeout <= 0 {
+		l.idleTimeout = DefaultIdleTimeout
+	}
+
+	return l
+}
+
+// Allow reports whether a request for key may proceed. Should it not, Allow
+// also returns the amount of time after which it would.
+func (l *Limiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evict(now)
+
+	var b *bucket
+	if e := l.buckets[key]; e != nil {
+		b = e.Value.(*bucket)
+		l.lru.MoveToFront(e)
+
+		elapsed := now.Sub(b.seen).Seconds()
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+	} else {
+		if len(l.buckets) >= l.maxKeys {
+			l.remove(l.lru.Back())
+		}
+
+		b = &bucket{key: key, tokens: l.burst}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+	b.seen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / l.rate
+
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// Len returns the number of keys l tracks.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// evict removes the buckets which have been idle for longer than the idle
+// timeout.
+func (l *Limiter) evict(now time.Time) {
+	for e := l.lru.Back(); e != nil; e = l.lru.Back() {
+		if now.Sub(e.Value.(*bucket).seen) <= l.idleTimeout {
+			break
+		}
+		l.remove(e)
+	}
+}
+
+func (l *Limiter) remove(e *list.Element) {
+	delete(l.buckets, e.Value.(*bucket).key)
+	l.lru.Remove(e)
+}
+
+// Middleware returns a handler which responds to the requests exceeding
+// their rate with 429 Too Many Requests, along with a Retry-After header, and
+// passes the rest on to next.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := l.keyFunc(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if ok, retryAfter := l.Allow(key); !ok {
+			secs := int64(math.Ceil(retryAfter.Seconds()))
+			if secs < 1 {
+				secs = 1
+			}
+
+			w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,188 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestIPKey(t *testing.T) {
+	cases := []struct {
+		ip  string
+		exp string
+	}{
+		0: {"", ""},
+		1: {"195.75.14.147", "195.75.14.147"},
+		2: {"::ffff:195.75.14.147", "195.75.14.147"},
+		3: {"2001:db8:1:2:3:4:5:6", "2001:db8:1:2::/64"},
+		4: {"2001:db8:1:2:ffff::1", "2001:db8:1:2::/64"},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			var ip net.IP
+			if kase.ip != "" {
+				ip = testutil.ParseIP(t, kase.ip)
+			}
+
+			testutil.AssertEqual(t, kase.exp, IPKey(ip))
+		})
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[fdaa::1]:1234"
+	testutil.AssertEqual(t, "fdaa::/64", ClientKey(req))
+
+	req.Header.Set("fly-client-ip", "195.75.14.147") //nolint:canonicalheader // fly dox specify this header
+	testutil.AssertEqual(t, "195.75.14.147", ClientKey(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "@"
+	testutil.AssertEqual(t, "", ClientKey(req))
+}
+
+func TestAllow(t *testing.T) {
+	l, now := newLimiter(&Options{Rate: 2, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("key")
+		testutil.AssertEqual(t, true, ok)
+	}
+
+	ok, retryAfter := l.Allow("key")
+	testutil.AssertEqual(t, false, ok)
+	testutil.AssertEqual(t, 500*time.Millisecond, retryAfter)
+
+	// other keys have buckets of their own
+	ok, _ = l.Allow("other")
+	testutil.AssertEqual(t, true, ok)
+
+	*now = now.Add(500 * time.Millisecond)
+	ok, _ = l.Allow("key")
+	testutil.AssertEqual(t, true, ok)
+
+	ok, _ = l.Allow("key")
+	testutil.AssertEqual(t, false, ok)
+
+	// buckets refill up to their burst
+	*now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _ = l.Allow("key")
+		testutil.AssertEqual(t, true, ok)
+	}
+	ok, _ = l.Allow("key")
+	testutil.AssertEqual(t, false, ok)
+}
+
+func TestEviction(t *testing.T) {
+	l, now := newLimiter(&Options{Rate: 1, MaxKeys: 3, IdleTimeout: time.Minute})
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, _ = l.Allow(key)
+		*now = now.Add(time.Second)
+	}
+	testutil.AssertEqual(t, 3, l.Len())
+
+	// "a" is the least recently seen key
+	_, _ = l.Allow("d")
+	testutil.AssertEqual(t, 3, l.Len())
+	ok, _ := l.Allow("a")
+	testutil.AssertEqual(t, true, ok) // a fresh bucket; "b" got evicted for it
+	ok, _ = l.Allow("d")
+	testutil.AssertEqual(t, false, ok)
+
+	// idle keys are evicted
+	*now = now.Add(2 * time.Minute)
+	_, _ = l.Allow("e")
+	testutil.AssertEqual(t, 1, l.Len())
+}
+
+func TestMiddleware(t *testing.T) {
+	l, _ := newLimiter(&Options{
+		Rate: 0.1,
+		KeyFunc: func(r *http.Request) string {
+			if r.URL.Path == "/unlimited" {
+				return ""
+			}
+
+			return ClientKey(r) + " " + r.URL.Path
+		},
+	})
+
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(path, clientIP string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("fly-client-ip", clientIP) //nolint:canonicalheader // fly dox specify this header
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	testutil.AssertEqual(t, http.StatusNoContent, serve("/a", "2001:db8::1").Code)
+
+	rec := serve("/a", "2001:db8::2") // same /64
+	testutil.AssertEqual(t, http.StatusTooManyRequests, rec.Code)
+	testutil.AssertEqual(t, "10", rec.Header().Get("Retry-After"))
+
+	testutil.AssertEqual(t, http.StatusNoContent, serve("/b", "2001:db8::1").Code)
+	testutil.AssertEqual(t, http.StatusNoContent, serve("/a", "2001:db8:0:1::1").Code)
+
+	for i := 0; i < 3; i++ {
+		testutil.AssertEqual(t, http.StatusNoContent, serve("/unlimited", "2001:db8::1").Code)
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	for _, opts := range []*Options{nil, {}} {
+		l := New(opts)
+
+		testutil.AssertEqual(t, float64(DefaultRate), l.rate)
+		testutil.AssertEqual(t, float64(1), l.burst)
+		testutil.AssertEqual(t, DefaultMaxKeys, l.maxKeys)
+		testutil.AssertEqual(t, DefaultIdleTimeout, l.idleTimeout)
+	}
+}
+
+func TestNewPanics(t *testing.T) {
+	cases := []*Options{
+		0: {Rate: -1},
+		1: {Rate: math.Inf(1)},
+		2: {Rate: math.NaN()},
+	}
+
+	for caseIndex := range cases {
+		opts := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			defer func() {
+				testutil.AssertEqual(t, "ratelimit: invalid rate", recover())
+			}()
+
+			New(opts)
+		})
+	}
+}
+
+func newLimiter(opts *Options) (*Limiter, *time.Time) {
+	now := time.Unix(1700000000, 0)
+
+	l := New(opts)
+	l.now = func() time.Time { return now }
+
+	return l, &now
+}
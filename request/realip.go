@@ -0,0 +1,74 @@
+package request
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+type remoteAddrKey struct{}
+
+// OriginalRemoteAddr returns the RemoteAddr a request had before
+// RealIPMiddleware rewrote it, if ctx is the context of such a request.
+func OriginalRemoteAddr(ctx context.Context) (addr string, ok bool) {
+	addr, ok = ctx.Value(remoteAddrKey{}).(string)
+
+	return
+}
+
+// RealIPMiddleware is shorthand for the RealIPMiddleware of a nil (trust all)
+// Policy.
+func RealIPMiddleware(next http.Handler) http.Handler {
+	return (*Policy)(nil).RealIPMiddleware(next)
+}
+
+// RealIPMiddleware returns a handler which, before calling next, rewrites the
+// RemoteAddr of trusted requests so that it refers to their client rather than
+// the proxy which forwarded them. The port of RemoteAddr is kept.
+//
+// The client address is read from the client address header (see ClientIP)
+// or, should that be missing or malformed, from the rightmost entry of
+// X-Forwarded-For which does not belong to the trusted networks of p.
+//
+// The original RemoteAddr of rewritten requests is available via
+// OriginalRemoteAddr.
+func (p *Policy) RealIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := p.realIP(r); ip != nil {
+			original := r.RemoteAddr
+
+			r = r.WithContext(context.WithValue(r.Context(), remoteAddrKey{}, original))
+			if _, port, err := net.SplitHostPort(original); err == nil {
+				r.RemoteAddr = net.JoinHostPort(ip.String(), port)
+			} else {
+				r.RemoteAddr = ip.String()
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// realIP returns the address of the client of r or nil, should r not be
+// trusted or carry it.
+func (p *Policy) realIP(r *http.Request) net.IP {
+	if !p.Trusts(r) {
+		return nil
+	}
+
+	if ip := ClientIP(r); ip != nil {
+		return ip
+	}
+
+	list := splitList(r.Header[ForwardedForHeader])
+	for i := len(list) - 1; i >= 0; i-- {
+		ip := parseForwardedAddr(list[i])
+		if ip == nil {
+			return nil // we can't tell who sent the entries to its left
+		} else if !p.contains(ip) {
+			return ip
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestRealIPMiddleware(t *testing.T) {
+	policy, err := NewPolicy("fdaa::/16", "10.0.0.0/8")
+	testutil.AssertEqual(t, nil, err)
+
+	cases := []struct {
+		policy       *Policy
+		remoteAddr   string
+		clientIP     string
+		forwardedFor string
+		exp          string
+	}{
+		0: {nil, "[fdaa::1]:1234", "", "", "[fdaa::1]:1234"},
+		1: {nil, "[fdaa::1]:1234", "195.75.14.147", "", "195.75.14.147:1234"},
+		2: {nil, "[fdaa::1]:1234", "6e4:dc69::822", "", "[6e4:dc69::822]:1234"},
+		3: {nil, "[fdaa::1]:1234", "not an ip", "1.1.1.1, 2.2.2.2", "2.2.2.2:1234"},
+		4: {policy, "[fdaa::1]:1234", "", "1.1.1.1, 2.2.2.2, 10.0.0.1", "2.2.2.2:1234"},
+		5: {policy, "[fdaa::1]:1234", "", "10.0.0.2, 10.0.0.1", "[fdaa::1]:1234"},
+		6: {policy, "[fdaa::1]:1234", "", "1.1.1.1, garbage, 10.0.0.1", "[fdaa::1]:1234"},
+		7: {policy, "3.3.3.3:1234", "195.75.14.147", "", "3.3.3.3:1234"},
+		8: {nil, "@", "195.75.14.147", "", "195.75.14.147"},
+		9: {policy, "[fdaa::1]:1234", "", "1.1.1.1, 2.2.2.2:5123, 10.0.0.1", "2.2.2.2:1234"},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = kase.remoteAddr
+			if kase.clientIP != "" {
+				req.Header.Set(ClientIPHeader, kase.clientIP)
+			}
+			if kase.forwardedFor != "" {
+				req.Header.Set(ForwardedForHeader, kase.forwardedFor)
+			}
+
+			var got *http.Request
+			kase.policy.RealIPMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = r
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			testutil.AssertEqual(t, kase.exp, got.RemoteAddr)
+			testutil.AssertEqual(t, kase.remoteAddr, req.RemoteAddr)
+
+			original, ok := OriginalRemoteAddr(got.Context())
+			testutil.AssertEqual(t, kase.exp != kase.remoteAddr, ok)
+			if ok {
+				testutil.AssertEqual(t, kase.remoteAddr, original)
+			}
+		})
+	}
+}
+
+func TestRealIPMiddlewareDefault(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[fdaa::1]:1234"
+	req.Header.Set(ClientIPHeader, "195.75.14.147")
+
+	var got string
+	RealIPMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.RemoteAddr
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	testutil.AssertEqual(t, "195.75.14.147:1234", got)
+}
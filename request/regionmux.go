@@ -0,0 +1,163 @@
+package request
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// groups maps the names of region groups to the regions they consist of.
+var groups = map[string][]string{
+	"africa":        {"jnb"},
+	"asia":          {"bom", "hkg", "nrt", "sin"},
+	"europe":        {"ams", "arn", "cdg", "fra", "lhr", "mad", "otp", "waw"},
+	"north-america": {"atl", "bos", "den", "dfw", "ewr", "gdl", "iad", "lax", "mia", "ord", "phx", "qro", "sea", "sjc", "yul", "yyz"},
+	"oceania":       {"syd"},
+	"south-america": {"bog", "eze", "gig", "gru", "scl"},
+
+	// the regions in member states of the European Union
+	"eu": {"ams", "arn", "cdg", "fra", "mad", "otp", "waw"},
+}
+
+// Group returns the regions the named group (e.g. "europe" or "eu") consists
+// of, in alphabetical order.
+func Group(name string) (regions []string, ok bool) {
+	var rs []string
+	if rs, ok = groups[strings.ToLower(name)]; ok {
+		regions = append(regions, rs...)
+	}
+
+	return
+}
+
+// Groups returns the names of the known region groups, in alphabetical order.
+func Groups() []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// RegionMux dispatches requests to handlers based on the region the edge
+// accepted them in (see Region).
+//
+// Patterns are comma-separated lists of region codes (e.g. "iad") and group
+// names (see Groups). Regions named explicitly take precedence over ones
+// matched via a group; among groups, the one registered first wins. Requests
+// whose region matches no pattern are served by Fallback.
+//
+// The zero value of RegionMux is ready to use.
+type RegionMux struct {
+	// Fallback denotes the handler of the requests whose region matches no
+	// pattern. Should Fallback be nil, such requests are responded to with
+	// 404 Not Found.
+	Fallback http.Handler
+
+	mu       sync.RWMutex // protects the fields below
+	explicit map[string]http.Handler
+	grouped  map[string]http.Handler
+}
+
+// NewRegionMux returns a new RegionMux which falls back to fallback.
+func NewRegionMux(fallback http.Handler) *RegionMux {
+	return &RegionMux{
+		Fallback: fallback,
+	}
+}
+
+// Handle registers h for the given pattern.
+//
+// Handle panics in case the pattern is malformed or names a region which has
+// already been explicitly registered.
+func (m *RegionMux) Handle(pattern string, h http.Handler) {
+	if h == nil {
+		panic("request: nil handler")
+	}
+
+	var regions, grouped []string
+	for _, elem := range strings.Split(pattern, ",") {
+		elem = strings.ToLower(strings.TrimSpace(elem))
+
+		if rs, ok := groups[elem]; ok {
+			grouped = append(grouped, rs...)
+		} else if isRegionCode(elem) {
+			regions = append(regions, elem)
+		} else {
+			panic(fmt.Sprintf("request: invalid region pattern %q", pattern))
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.explicit == nil {
+		m.explicit = make(map[string]http.Handler)
+		m.grouped = make(map[string]http.Handler)
+	}
+
+	for _, region := range regions {
+		if _, dup := m.explicit[region]; dup {
+			panic(fmt.Sprintf("request: multiple registrations for region %q", region))
+		}
+	}
+
+	for _, region := range regions {
+		m.explicit[region] = h
+	}
+	for _, region := range grouped {
+		if _, ok := m.grouped[region]; !ok {
+			m.grouped[region] = h
+		}
+	}
+}
+
+// HandleFunc registers fn for the given pattern. Refer to Handle for the
+// details.
+func (m *RegionMux) HandleFunc(pattern string, fn func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(fn))
+}
+
+// Handler returns the handler of the requests accepted in the given region.
+func (m *RegionMux) Handler(region string) http.Handler {
+	region = strings.ToLower(region)
+
+	m.mu.RLock()
+	h := m.explicit[region]
+	if h == nil {
+		h = m.grouped[region]
+	}
+	m.mu.RUnlock()
+
+	switch {
+	case h != nil:
+		return h
+	case m.Fallback != nil:
+		return m.Fallback
+	default:
+		return http.NotFoundHandler()
+	}
+}
+
+// ServeHTTP implements http.Handler for RegionMux.
+func (m *RegionMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.Handler(Region(r)).ServeHTTP(w, r)
+}
+
+func isRegionCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+
+	for _, c := range s {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
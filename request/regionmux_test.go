@@ -0,0 +1,117 @@
+package request
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestRegionMux(t *testing.T) {
+	respond := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = io.WriteString(w, body)
+		})
+	}
+
+	m := NewRegionMux(respond("fallback"))
+	m.Handle("eu", respond("eu"))
+	m.Handle("europe", respond("europe"))
+	m.Handle("IAD, ord", respond("list"))
+	m.HandleFunc("ams", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ams")
+	})
+
+	cases := []struct {
+		region string
+		exp    string
+	}{
+		0: {"", "fallback"},
+		1: {"ams", "ams"},
+		2: {"AMS", "ams"},
+		3: {"fra", "eu"},
+		4: {"lhr", "europe"},
+		5: {"iad", "list"},
+		6: {"ord", "list"},
+		7: {"syd", "fallback"},
+		8: {"xyz", "fallback"},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("fly-region", kase.region) //nolint:canonicalheader // fly dox specify this header
+
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			testutil.AssertEqual(t, kase.exp, rec.Body.String())
+		})
+	}
+}
+
+func TestRegionMuxZeroValue(t *testing.T) {
+	var m RegionMux
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("fly-region", "iad") //nolint:canonicalheader // fly dox specify this header
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.AssertEqual(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRegionMuxPanics(t *testing.T) {
+	cases := []string{
+		0: "",
+		1: "iad,",
+		2: "not-a-group",
+		3: "iad2",
+		4: "ord", // registered below
+	}
+
+	for caseIndex := range cases {
+		pattern := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			m := NewRegionMux(nil)
+			m.Handle("ord", http.NotFoundHandler())
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q: expected panic", pattern)
+				}
+			}()
+
+			m.Handle(pattern, http.NotFoundHandler())
+		})
+	}
+}
+
+func TestGroups(t *testing.T) {
+	names := Groups()
+	testutil.AssertEqual(t, true, sort.StringsAreSorted(names))
+
+	for _, name := range names {
+		regions, ok := Group(name)
+		testutil.AssertEqual(t, true, ok)
+		testutil.AssertEqual(t, true, sort.StringsAreSorted(regions))
+
+		for _, region := range regions {
+			testutil.AssertEqual(t, true, isRegionCode(region))
+		}
+	}
+
+	eu, ok := Group("EU")
+	testutil.AssertEqual(t, true, ok)
+	testutil.AssertEqual(t, 7, len(eu))
+
+	_, ok = Group("atlantis")
+	testutil.AssertEqual(t, false, ok)
+}
@@ -17,6 +17,14 @@ func TestHeadersAreCanonical(t *testing.T) {
 		"RegionHeader":        RegionHeader,
 		"ClientIPHeader":      ClientIPHeader,
 		"ForwardedPortHeader": ForwardedPortHeader,
+
+		"ForwardedProtoHeader": ForwardedProtoHeader,
+		"ForwardedSSLHeader":   ForwardedSSLHeader,
+		"ViaHeader":            ViaHeader,
+		"ForwardedForHeader":   ForwardedForHeader,
+		"RequestStartHeader":   RequestStartHeader,
+
+		"XForwardedProtoHeader": XForwardedProtoHeader,
 	}
 
 	for name := range headers {
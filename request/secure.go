@@ -0,0 +1,130 @@
+package request
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azazeal/fly/sixpn"
+)
+
+// XForwardedProtoHeader denotes the de facto standard header carrying the
+// protocol the client connected to a proxy with.
+const XForwardedProtoHeader = "X-Forwarded-Proto"
+
+// IsSecure reports whether the client of r connected over TLS, either to the
+// local instance or, since fly terminates TLS at the edge, to the proxy.
+//
+// The proxy headers are consulted in order of authority: Fly-Forwarded-Proto,
+// when present, decides alone; Fly-Forwarded-Ssl and X-Forwarded-Proto are
+// only consulted in its absence.
+func IsSecure(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	if v := fetch(r, ForwardedProtoHeader); v != "" {
+		return strings.EqualFold(v, "https")
+	}
+
+	if v := fetch(r, ForwardedSSLHeader); v != "" {
+		return strings.EqualFold(v, "on")
+	}
+
+	return strings.EqualFold(fetch(r, XForwardedProtoHeader), "https")
+}
+
+// HTTPSOptions wraps the options RedirectHTTPS accepts.
+type HTTPSOptions struct {
+	// Code denotes the status code of the redirects. Should Code be zero,
+	// http.StatusPermanentRedirect is used.
+	Code int
+
+	// MaxAge denotes the max-age of the Strict-Transport-Security header
+	// secure responses carry. Should MaxAge be less than a second, the header
+	// is omitted.
+	MaxAge time.Duration
+
+	// IncludeSubDomains, when set, adds the includeSubDomains directive to the
+	// Strict-Transport-Security header.
+	IncludeSubDomains bool
+
+	// Preload, when set, adds the preload directive to the
+	// Strict-Transport-Security header.
+	Preload bool
+
+	// ExemptPaths denotes the paths (e.g. those of health checks) which are
+	// served regardless of whether the request is secure.
+	ExemptPaths []string
+}
+
+// RedirectHTTPS returns a handler which redirects insecure requests (see
+// IsSecure) to their HTTPS equivalent and passes the rest on to next.
+//
+// Requests for the exempt paths as well as ones sent over 6PN without going
+// through the proxy are never redirected.
+//
+// A nil opts is treated as the zero value of HTTPSOptions.
+func RedirectHTTPS(next http.Handler, opts *HTTPSOptions) http.Handler {
+	if opts == nil {
+		opts = &HTTPSOptions{}
+	}
+
+	code := opts.Code
+	if code == 0 {
+		code = http.StatusPermanentRedirect
+	}
+
+	hsts := strictTransportSecurity(opts)
+
+	exempt := make(map[string]struct{}, len(opts.ExemptPaths))
+	for _, path := range opts.ExemptPaths {
+		exempt[path] = struct{}{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch _, ok := exempt[r.URL.Path]; {
+		case IsSecure(r):
+			if hsts != "" {
+				w.Header().Set("Strict-Transport-Security", hsts)
+			}
+		case ok, isInternal(r):
+			break
+		default:
+			target := "https://" + r.Host + r.URL.RequestURI()
+			http.Redirect(w, r, target, code)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func strictTransportSecurity(opts *HTTPSOptions) string {
+	secs := int64(opts.MaxAge / time.Second)
+	if secs < 1 {
+		return ""
+	}
+
+	v := "max-age=" + strconv.FormatInt(secs, 10)
+	if opts.IncludeSubDomains {
+		v += "; includeSubDomains"
+	}
+	if opts.Preload {
+		v += "; preload"
+	}
+
+	return v
+}
+
+// isInternal reports whether r was sent over 6PN without going through the
+// proxy.
+func isInternal(r *http.Request) bool {
+	if !sixpn.Is6PN(RemoteIP(r)) {
+		return false
+	}
+
+	return fetch(r, ForwardedProtoHeader) == "" && fetch(r, ForwardedSSLHeader) == ""
+}
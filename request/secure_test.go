@@ -0,0 +1,128 @@
+package request
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestIsSecure(t *testing.T) {
+	cases := []struct {
+		headers map[string]string
+		tls     bool
+		exp     bool
+	}{
+		0: {},
+		1: {tls: true, exp: true},
+		2: {headers: map[string]string{ForwardedProtoHeader: "https"}, exp: true},
+		3: {headers: map[string]string{ForwardedProtoHeader: "HTTPS"}, exp: true},
+		4: {headers: map[string]string{ForwardedProtoHeader: "http"}},
+		5: {headers: map[string]string{ForwardedSSLHeader: "on"}, exp: true},
+		6: {headers: map[string]string{ForwardedSSLHeader: "off"}},
+		7: {headers: map[string]string{XForwardedProtoHeader: "https"}, exp: true},
+		8: {headers: map[string]string{XForwardedProtoHeader: "http"}},
+		9: {
+			// the proxy's own header is authoritative
+			headers: map[string]string{
+				ForwardedProtoHeader:  "http",
+				ForwardedSSLHeader:    "on",
+				XForwardedProtoHeader: "https",
+			},
+		},
+		10: {
+			headers: map[string]string{
+				ForwardedSSLHeader:    "off",
+				XForwardedProtoHeader: "https",
+			},
+		},
+		11: {
+			headers: map[string]string{
+				ForwardedProtoHeader:  "https",
+				XForwardedProtoHeader: "http",
+			},
+			exp: true,
+		},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range kase.headers {
+				req.Header.Set(k, v)
+			}
+			if kase.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			testutil.AssertEqual(t, kase.exp, IsSecure(req))
+		})
+	}
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	h := RedirectHTTPS(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), &HTTPSOptions{
+		MaxAge:            365 * 24 * time.Hour,
+		IncludeSubDomains: true,
+		Preload:           true,
+		ExemptPaths:       []string{"/healthz"},
+	})
+
+	const hsts = "max-age=31536000; includeSubDomains; preload"
+
+	cases := []struct {
+		target     string
+		remoteAddr string
+		proto      string
+		code       int
+		location   string
+		hsts       string
+	}{
+		0: {"/path?q=1", "1.2.3.4:1234", "http", http.StatusPermanentRedirect, "https://example.com/path?q=1", ""},
+		1: {"/path", "1.2.3.4:1234", "https", http.StatusNoContent, "", hsts},
+		2: {"/healthz", "1.2.3.4:1234", "http", http.StatusNoContent, "", ""},
+		3: {"/healthz/more", "1.2.3.4:1234", "http", http.StatusPermanentRedirect, "https://example.com/healthz/more", ""},
+		4: {"/path", "[fdaa:0:22b7::2]:1234", "", http.StatusNoContent, "", ""},
+		5: {"/path", "[fdaa:0:22b7::2]:1234", "http", http.StatusPermanentRedirect, "https://example.com/path", ""},
+		6: {"/path", "1.2.3.4:1234", "", http.StatusPermanentRedirect, "https://example.com/path", ""},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+kase.target, nil)
+			req.RemoteAddr = kase.remoteAddr
+			if kase.proto != "" {
+				req.Header.Set(ForwardedProtoHeader, kase.proto)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			testutil.AssertEqual(t, kase.code, rec.Code)
+			testutil.AssertEqual(t, kase.location, rec.Header().Get("Location"))
+			testutil.AssertEqual(t, kase.hsts, rec.Header().Get("Strict-Transport-Security"))
+		})
+	}
+}
+
+func TestRedirectHTTPSDefaults(t *testing.T) {
+	h := RedirectHTTPS(http.NotFoundHandler(), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set(ForwardedProtoHeader, "https")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	testutil.AssertEqual(t, http.StatusNotFound, rec.Code)
+	testutil.AssertEqual(t, "", rec.Header().Get("Strict-Transport-Security"))
+}
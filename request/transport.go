@@ -0,0 +1,70 @@
+package request
+
+import "net/http"
+
+// PropagatingTransport is an http.RoundTripper which attaches the ID of the
+// inbound request a call is made on behalf of to outbound requests, so that
+// logs may be correlated across applications.
+//
+// The ID is read from the context of the outbound request (see IDFromContext),
+// which IDMiddleware or Middleware populate. Outbound requests which already
+// carry the header are left as is while ones made outside the scope of an
+// identified request are assigned a new ID (see NewID).
+type PropagatingTransport struct {
+	// Base denotes the RoundTripper requests are sent via. Should Base be nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// Header denotes the header the ID is sent under. Should Header be empty,
+	// IDHeader is used.
+	Header string
+
+	// Headers denotes the headers of the inbound request (e.g. RegionHeader)
+	// which are copied onto the outbound requests lacking them. Much like the
+	// ID, they're read from the context of the outbound request, which
+	// IDMiddleware or Middleware populate.
+	Headers []string
+}
+
+// RoundTrip implements http.RoundTripper for PropagatingTransport.
+func (pt *PropagatingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	header := pt.Header
+	if header == "" {
+		header = IDHeader
+	}
+
+	ctx := r.Context()
+
+	var (
+		inbound = headerFromContext(ctx)
+		set     = make(http.Header)
+	)
+	for _, key := range pt.Headers {
+		if v := inbound.Get(key); v != "" && r.Header.Get(key) == "" {
+			set.Set(key, v)
+		}
+	}
+
+	if r.Header.Get(header) == "" && set.Get(header) == "" {
+		id, ok := IDFromContext(ctx)
+		if !ok {
+			id = NewID()
+		}
+		set.Set(header, id)
+	}
+
+	if len(set) > 0 {
+		// RoundTrippers should not modify the requests they're given
+		r = r.Clone(ctx)
+		for key, values := range set {
+			r.Header[key] = values
+		}
+	}
+
+	base := pt.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(r)
+}
@@ -0,0 +1,106 @@
+package request
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/azazeal/fly/env"
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestPropagatingTransport(t *testing.T) {
+	t.Setenv(env.RegionKey, "")
+
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	t.Cleanup(srv.Close)
+
+	cases := []struct {
+		header   string
+		inbound  string
+		outbound string
+		exp      string
+	}{
+		0: {inbound: "inbound-id", exp: "inbound-id"},
+		1: {header: "X-Request-Id", inbound: "inbound-id", exp: "inbound-id"},
+		2: {inbound: "inbound-id", outbound: "outbound-id", exp: "outbound-id"},
+		3: {},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			client := &http.Client{
+				Transport: &PropagatingTransport{Header: kase.header},
+			}
+
+			ctx := context.TODO()
+			if kase.inbound != "" {
+				ctx = NewContext(ctx, Info{ID: kase.inbound, Valid: FieldID})
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+			testutil.AssertEqual(t, nil, err)
+
+			header := kase.header
+			if header == "" {
+				header = IDHeader
+			}
+			if kase.outbound != "" {
+				req.Header.Set(header, kase.outbound)
+			}
+
+			res, err := client.Do(req)
+			testutil.AssertEqual(t, nil, err)
+			_ = res.Body.Close()
+
+			if kase.exp == "" {
+				testutil.AssertEqual(t, 26, len(got.Get(header))) // no region suffix
+			} else {
+				testutil.AssertEqual(t, kase.exp, got.Get(header))
+			}
+			testutil.AssertEqual(t, kase.outbound, req.Header.Get(header))
+		})
+	}
+}
+
+func TestPropagatingTransportViaIDMiddleware(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &http.Client{
+		Transport: &PropagatingTransport{
+			Headers: []string{RegionHeader, ForwardedProtoHeader},
+		},
+	}
+
+	h := IDMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, srv.URL, nil)
+		testutil.AssertEqual(t, nil, err)
+		req.Header.Set(ForwardedProtoHeader, "http")
+
+		res, err := client.Do(req)
+		testutil.AssertEqual(t, nil, err)
+		_ = res.Body.Close()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("fly-region", "iad")            //nolint:canonicalheader // fly dox specify this header
+	req.Header.Set("fly-forwarded-proto", "https") //nolint:canonicalheader // fly dox specify this header
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	testutil.AssertEqual(t, rec.Header().Get(IDHeader), got.Get(IDHeader))
+	testutil.AssertEqual(t, "iad", got.Get(RegionHeader))
+	testutil.AssertEqual(t, "http", got.Get(ForwardedProtoHeader))
+}
@@ -0,0 +1,59 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PublicURL returns the absolute URL the client of r requested; that is one
+// made up of the scheme the client connected to the edge with (see IsSecure),
+// the host it requested, the port the edge accepted the request on (see
+// ForwardedPort) and the path and query of r.
+//
+// The port is omitted when it's the default one for the scheme.
+func PublicURL(r *http.Request) *url.URL {
+	scheme, defaultPort := "http", 80
+	if IsSecure(r) {
+		scheme, defaultPort = "https", 443
+	}
+
+	host, port := r.Host, 0
+	if h, p, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+		port, _ = strconv.Atoi(p)
+	}
+	if fp := ForwardedPort(r); fp != 0 {
+		port = fp
+	}
+
+	if port != 0 && port != defaultPort {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+
+	return &url.URL{
+		Scheme:   scheme,
+		Host:     host,
+		Path:     r.URL.Path,
+		RawPath:  r.URL.RawPath,
+		RawQuery: r.URL.RawQuery,
+	}
+}
+
+// SiblingURL resolves ref, which may not refer to a different host, against
+// the public URL of r (see PublicURL); e.g. a ref of "/oauth/callback" yields
+// the URL of the callback on the public origin of r.
+func SiblingURL(r *http.Request, ref string) (*url.URL, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "" || u.Host != "" {
+		return nil, fmt.Errorf("request: %q is not relative to the public origin", ref)
+	}
+
+	return PublicURL(r).ResolveReference(u), nil
+}
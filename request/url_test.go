@@ -0,0 +1,79 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+func TestPublicURL(t *testing.T) {
+	cases := []struct {
+		target string
+		proto  string
+		port   string
+		exp    string
+	}{
+		0: {"http://example.com/a/b?c=d", "", "", "http://example.com/a/b?c=d"},
+		1: {"http://example.com/", "https", "443", "https://example.com/"},
+		2: {"http://example.com/", "https", "8443", "https://example.com:8443/"},
+		3: {"http://example.com/", "http", "80", "http://example.com/"},
+		4: {"http://example.com:8080/", "http", "", "http://example.com:8080/"},
+		5: {"http://example.com:8080/", "https", "443", "https://example.com/"},
+		6: {"http://[::1]:8080/", "https", "443", "https://[::1]/"},
+		7: {"http://[::1]:8080/", "https", "", "https://[::1]:8080/"},
+		8: {"http://example.com/a%2Fb", "", "", "http://example.com/a%2Fb"},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, kase.target, nil)
+			if kase.proto != "" {
+				req.Header.Set(ForwardedProtoHeader, kase.proto)
+			}
+			if kase.port != "" {
+				req.Header.Set(ForwardedPortHeader, kase.port)
+			}
+
+			testutil.AssertEqual(t, kase.exp, PublicURL(req).String())
+		})
+	}
+}
+
+func TestSiblingURL(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/login?next=%2F", nil)
+	req.Header.Set(ForwardedProtoHeader, "https")
+	req.Header.Set(ForwardedPortHeader, "8443")
+
+	cases := []struct {
+		ref string
+		exp string
+	}{
+		0: {"/oauth/callback", "https://example.com:8443/oauth/callback"},
+		1: {"callback?state=1", "https://example.com:8443/callback?state=1"},
+		2: {"", "https://example.com:8443/login?next=%2F"},
+		3: {"https://evil.com/", ""},
+		4: {"//evil.com/", ""},
+		5: {"%zz", ""},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			got, err := SiblingURL(req, kase.ref)
+			if kase.exp == "" {
+				testutil.AssertEqual(t, false, err == nil)
+
+				return
+			}
+
+			testutil.AssertEqual(t, nil, err)
+			testutil.AssertEqual(t, kase.exp, got.String())
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Package sixpn implements functionality for when dealing with the addresses
+// of fly's private network (6PN).
+//
+// 6PN addresses belong to fdaa::/16. The 32 bits which follow identify the
+// organization network the address belongs to; the 32 bits after those
+// identify the host within the network while the remaining 48 identify the
+// instance (or peer) on the host.
+//
+// Fly only documents the organization part of the layout; the host and
+// instance parts reflect the layout observed in practice.
+package sixpn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// The lengths, in bits, of the prefixes 6PN addresses are made of.
+const (
+	// PrefixLen denotes the length of the prefix all 6PN addresses share.
+	PrefixLen = 16
+
+	// NetworkPrefixLen denotes the length of the prefix 6PN addresses of the
+	// same organization network share.
+	NetworkPrefixLen = PrefixLen + 32
+
+	// HostPrefixLen denotes the length of the prefix 6PN addresses of the same
+	// host share.
+	HostPrefixLen = NetworkPrefixLen + 32
+)
+
+// ErrNot6PN is returned by Parse for addresses which do not belong to 6PN.
+var ErrNot6PN = errors.New("sixpn: not a 6PN address")
+
+// Prefix returns the network all 6PN addresses belong to (fdaa::/16).
+func Prefix() *net.IPNet {
+	return &net.IPNet{
+		IP:   net.IP{0xfd, 0xaa, 15: 0},
+		Mask: net.CIDRMask(PrefixLen, 8*net.IPv6len),
+	}
+}
+
+// Is6PN reports whether ip belongs to 6PN.
+func Is6PN(ip net.IP) bool {
+	ip = ip.To16()
+
+	return ip != nil && ip[0] == 0xfd && ip[1] == 0xaa
+}
+
+// SameOrg reports whether a and b are 6PN addresses of the same organization
+// network.
+func SameOrg(a, b net.IP) bool {
+	if !Is6PN(a) || !Is6PN(b) {
+		return false
+	}
+
+	return binary.BigEndian.Uint32(a.To16()[2:]) == binary.BigEndian.Uint32(b.To16()[2:])
+}
+
+// Addr wraps the components of a 6PN address.
+type Addr struct {
+	// Network denotes the ID of the organization network.
+	Network uint32
+
+	// Host denotes the ID of the host within the organization network.
+	Host uint32
+
+	// Instance denotes the 48-bit ID of the instance on the host.
+	Instance uint64
+}
+
+// Parse splits the given 6PN address into its components.
+func Parse(ip net.IP) (addr Addr, err error) {
+	if !Is6PN(ip) {
+		err = fmt.Errorf("%w: %s", ErrNot6PN, ip)
+
+		return
+	}
+	ip = ip.To16()
+
+	addr.Network = binary.BigEndian.Uint32(ip[2:])
+	addr.Host = binary.BigEndian.Uint32(ip[6:])
+	addr.Instance = binary.BigEndian.Uint64(ip[8:]) & (1<<48 - 1)
+
+	return
+}
+
+// ParseString parses s as a 6PN address and splits it into its components.
+func ParseString(s string) (Addr, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return Addr{}, fmt.Errorf("sixpn: invalid address %q", s)
+	}
+
+	return Parse(ip)
+}
+
+// IP returns the address a represents.
+func (a Addr) IP() net.IP {
+	ip := make(net.IP, net.IPv6len)
+	ip[0], ip[1] = 0xfd, 0xaa
+	binary.BigEndian.PutUint32(ip[2:], a.Network)
+	binary.BigEndian.PutUint32(ip[6:], a.Host)
+	binary.BigEndian.PutUint16(ip[10:], uint16(a.Instance>>32))
+	binary.BigEndian.PutUint32(ip[12:], uint32(a.Instance))
+
+	return ip
+}
+
+// NetworkPrefix returns the prefix of the organization network a belongs to.
+func (a Addr) NetworkPrefix() *net.IPNet {
+	return &net.IPNet{
+		IP:   a.IP().Mask(net.CIDRMask(NetworkPrefixLen, 8*net.IPv6len)),
+		Mask: net.CIDRMask(NetworkPrefixLen, 8*net.IPv6len),
+	}
+}
+
+// HostPrefix returns the prefix of the host a belongs to.
+func (a Addr) HostPrefix() *net.IPNet {
+	return &net.IPNet{
+		IP:   a.IP().Mask(net.CIDRMask(HostPrefixLen, 8*net.IPv6len)),
+		Mask: net.CIDRMask(HostPrefixLen, 8*net.IPv6len),
+	}
+}
+
+// String implements fmt.Stringer for Addr. It returns the hexadecimal
+// representations of the network, host and instance IDs, separated by dashes
+// (e.g. 000022b7-0a7b0abd-aa3c64980002).
+func (a Addr) String() string {
+	return fmt.Sprintf("%08x-%08x-%012x", a.Network, a.Host, a.Instance)
+}
@@ -0,0 +1,97 @@
+package sixpn
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/azazeal/fly/internal/testutil"
+)
+
+const (
+	instance1 = "fdaa:0:22b7:a7b:abd:aa3c:6498:2"
+	instance2 = "fdaa:0:22b7:a7b:ab8:3071:ecb3:2"
+	peer      = "fdaa:0:22b7:a8b:ce2:0:a:c02"
+	otherOrg  = "fdaa:0:33c1:a7b:abd:aa3c:6498:2"
+)
+
+func TestIs6PN(t *testing.T) {
+	cases := []struct {
+		ip  net.IP
+		exp bool
+	}{
+		0: {},
+		1: {ip: testutil.ParseIP(t, instance1), exp: true},
+		2: {ip: testutil.ParseIP(t, "fdaa::3"), exp: true},
+		3: {ip: testutil.ParseIP(t, "fdab::3")},
+		4: {ip: testutil.ParseIP(t, "172.16.4.2")},
+		5: {ip: testutil.ParseIP(t, "172.16.4.2").To4()},
+		6: {ip: net.IP{0xfd, 0xaa}},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			testutil.AssertEqual(t, kase.exp, Is6PN(kase.ip))
+		})
+	}
+}
+
+func TestSameOrg(t *testing.T) {
+	cases := []struct {
+		a, b string
+		exp  bool
+	}{
+		0: {a: instance1, b: instance2, exp: true},
+		1: {a: instance1, b: peer, exp: true},
+		2: {a: instance1, b: otherOrg},
+		3: {a: instance1, b: "::1"},
+		4: {a: "10.0.0.1", b: "10.0.0.1"},
+	}
+
+	for caseIndex := range cases {
+		kase := cases[caseIndex]
+
+		t.Run(strconv.Itoa(caseIndex), func(t *testing.T) {
+			a, b := testutil.ParseIP(t, kase.a), testutil.ParseIP(t, kase.b)
+
+			testutil.AssertEqual(t, kase.exp, SameOrg(a, b))
+			testutil.AssertEqual(t, kase.exp, SameOrg(b, a))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	ip := testutil.ParseIP(t, instance1)
+
+	addr, err := Parse(ip)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, Addr{
+		Network:  0x000022b7,
+		Host:     0x0a7b0abd,
+		Instance: 0xaa3c64980002,
+	}, addr)
+
+	testutil.AssertEqual(t, ip, addr.IP())
+	testutil.AssertEqual(t, "000022b7-0a7b0abd-aa3c64980002", addr.String())
+	testutil.AssertEqual(t, "fdaa:0:22b7::/48", addr.NetworkPrefix().String())
+	testutil.AssertEqual(t, "fdaa:0:22b7:a7b:abd::/80", addr.HostPrefix().String())
+
+	testutil.AssertEqual(t, true, addr.NetworkPrefix().Contains(testutil.ParseIP(t, peer)))
+	testutil.AssertEqual(t, false, addr.NetworkPrefix().Contains(testutil.ParseIP(t, otherOrg)))
+	testutil.AssertEqual(t, true, Prefix().Contains(ip))
+}
+
+func TestParseString(t *testing.T) {
+	addr, err := ParseString(peer)
+	testutil.AssertEqual(t, nil, err)
+	testutil.AssertEqual(t, "000022b7-0a8b0ce2-0000000a0c02", addr.String())
+
+	_, err = ParseString("not an ip")
+	testutil.AssertEqual(t, false, err == nil)
+
+	_, err = ParseString("10.0.0.1")
+	testutil.AssertEqual(t, true, errors.Is(err, ErrNot6PN))
+}